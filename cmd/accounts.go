@@ -29,10 +29,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/spf13/viper"
-
-	"github.com/jammystuff/pennychallenge/monzo"
-
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
@@ -54,24 +50,16 @@ func runAccounts(cmd *cobra.Command, args []string) {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"ID", "Description", "Account Type"})
 
-	t, err := readToken()
-	if err != nil {
-		fmt.Printf("Error reading access token: %v\n", err)
-		os.Exit(1)
-	}
+	ctx, cancel := newContext(DefaultTimeout)
+	defer cancel()
 
-	clientID := viper.GetString("client_id")
-	clientSecret := viper.GetString("client_secret")
-	refresh, err := refreshToken(clientID, clientSecret, t)
+	client, err := newClient(ctx)
 	if err != nil {
-		fmt.Printf("Error refreshing access token: %v\n", err)
+		fmt.Printf("Error getting access token: %v\n", err)
 		os.Exit(1)
 	}
 
-	token := refresh.AccessToken
-	client := monzo.NewClient(token)
-
-	accounts, err := client.Accounts()
+	accounts, err := client.Accounts(ctx)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
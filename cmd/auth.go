@@ -26,149 +26,318 @@
 package cmd
 
 import (
-	"encoding/json"
+	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/jammystuff/pennychallenge/monzo"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-const baseAuthURL = "https://auth.monzo.com"
-const redirectURL = "http://localhost"
-const tokenPath = "pennychallengetoken.json"
-const tokenURL = "https://api.monzo.com/oauth2/token"
+const defaultAuthURL = "https://auth.monzo.com"
+const defaultApprovalTimeout = 5 * time.Minute
+const defaultCallbackPort = 8080
+const approvalPollInterval = 5 * time.Second
+const legacyTokenPath = "pennychallengetoken.json"
+const tokenFileName = "token.json"
+
+const (
+	accessTokenVar  = "MONZO_ACCESS_TOKEN"
+	refreshTokenVar = "MONZO_REFRESH_TOKEN"
+)
 
 // authCmd represents the auth command
 var authCmd = &cobra.Command{
 	Use:   "auth",
 	Short: "Authenticate to Monzo",
-	Long:  `Authenticates to the Monzo API.`,
-	Run:   runAuth,
+	Long: `Authenticates to the Monzo API.
+
+A temporary server is started on localhost to receive the OAuth redirect, so
+the redirect URL of the Monzo API client must be set to
+http://localhost:<port>/callback. If the redirect can't be received, paste the
+URL the browser was redirected to instead. The state parameter of the redirect
+is checked in either case.
+
+Older versions used http://localhost as the redirect URL. Either change the
+redirect URL of the Monzo API client, or keep the old one with
+--redirect-url http://localhost (or redirect_url in the config file).`,
+	Run: runAuth,
 }
 
 func init() {
 	rootCmd.AddCommand(authCmd)
+
+	authCmd.Flags().IntP("port", "p", defaultCallbackPort, "Port to receive the OAuth redirect on")
+	viper.BindPFlag("callback_port", authCmd.Flags().Lookup("port"))
+
+	authCmd.Flags().String("redirect-url", "", "OAuth redirect URL registered for the Monzo API client (default http://localhost:<port>/callback)")
+	viper.BindPFlag("redirect_url", authCmd.Flags().Lookup("redirect-url"))
+
+	authCmd.Flags().Duration("approval-timeout", defaultApprovalTimeout, "How long to wait for access to be approved in the Monzo app")
+	viper.BindPFlag("approval_timeout", authCmd.Flags().Lookup("approval-timeout"))
 }
 
-type Token struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+// waitForApproval polls the API until the user has approved access in the
+// Monzo app. Strong Customer Authentication means account data is refused with
+// a 403 status code until then.
+func waitForApproval(ctx context.Context, client *monzo.Client) error {
+	for {
+		whoAmI, err := client.WhoAmI(ctx)
+		if err == nil && whoAmI.Authenticated {
+			_, err = client.Accounts(ctx)
+			if err == nil {
+				return nil
+			}
+		}
+		if err != nil && !monzo.IsForbidden(err) {
+			return err
+		}
+
+		timer := time.NewTimer(approvalPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
 }
 
-func getAuthURL(clientID string) string {
+func getAuthURL(clientID, state string) string {
 	values := url.Values{}
 	values.Add("client_id", clientID)
-	values.Add("redirect_uri", redirectURL)
+	values.Add("redirect_uri", redirectURL())
 	values.Add("response_type", "code")
+	values.Add("state", state)
 	query := values.Encode()
 
-	return fmt.Sprintf("%s/?%s", baseAuthURL, query)
+	authURL := strings.TrimSuffix(viper.GetString("auth_url"), "/")
+	return fmt.Sprintf("%s/?%s", authURL, query)
 }
 
-func getToken(clientID, clientSecret, authCode string) (*Token, error) {
-	values := url.Values{}
-	values.Add("grant_type", "authorization_code")
-	values.Add("client_id", clientID)
-	values.Add("client_secret", clientSecret)
-	values.Add("redirect_uri", redirectURL)
-	values.Add("code", authCode)
-	body := strings.NewReader(values.Encode())
-
-	resp, err := http.Post(tokenURL, "application/x-www-form-urlencoded", body)
-	if err != nil {
-		return nil, err
+// migrateLegacyToken moves a token file left in the working directory by
+// older versions into store, which keeps it in path, unless a token already
+// exists there.
+func migrateLegacyToken(path string, store monzo.TokenStore) error {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("%d status code when getting token", resp.StatusCode)
-		return nil, errors.New(msg)
+	token, err := (&monzo.FileTokenStore{Path: legacyTokenPath}).Load()
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
 	}
 
-	defer resp.Body.Close()
-	respBody, err := ioutil.ReadAll(resp.Body)
+	err = store.Save(token)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var token Token
-	err = json.Unmarshal(respBody, &token)
-	return &token, err
+	fmt.Printf("Moved access token from %s to %s\n", legacyTokenPath, path)
+	return os.Remove(legacyTokenPath)
 }
 
-func readToken() (*Token, error) {
-	data, err := ioutil.ReadFile(tokenPath)
+// newTokenStore returns the token store selected by token_store:
+//
+//	file       plaintext JSON in the token file
+//	encrypted  encrypted JSON in the token file
+//	env        read-only, from MONZO_ACCESS_TOKEN and MONZO_REFRESH_TOKEN,
+//	           suffixed with _<PROFILE> when a profile is selected
+//	memory     seeded from the same environment variables, but keeps
+//	           refreshed tokens in memory for the rest of the run
+//
+// If token_store is not set, the encrypted store is used when a passphrase or
+// key file is configured and the plaintext one otherwise.
+func newTokenStore() monzo.TokenStore {
+	passphrase, err := tokenPassphrase()
 	if err != nil {
-		return nil, err
+		fmt.Printf("Error reading token key file: %v\n", err)
+		os.Exit(1)
+	}
+
+	envStore := &monzo.EnvTokenStore{
+		AccessTokenVar:  profileVar(accessTokenVar),
+		RefreshTokenVar: profileVar(refreshTokenVar),
+	}
+
+	var store monzo.TokenStore
+	switch storeType := configString("token_store"); storeType {
+	case "":
+		if passphrase != nil {
+			store = &monzo.EncryptedFileTokenStore{Path: tokenFile(), Passphrase: passphrase}
+		} else {
+			store = &monzo.FileTokenStore{Path: tokenFile()}
+		}
+	case "file":
+		store = &monzo.FileTokenStore{Path: tokenFile()}
+	case "encrypted":
+		if passphrase == nil {
+			fmt.Println("Encrypted token store requires token_passphrase or token_key_file")
+			os.Exit(1)
+		}
+		store = &monzo.EncryptedFileTokenStore{Path: tokenFile(), Passphrase: passphrase}
+	case "env":
+		return envStore
+	case "memory":
+		token, _ := envStore.Load()
+		return monzo.NewMemoryTokenStore(token)
+	default:
+		fmt.Printf("Unknown token store %s\n", storeType)
+		os.Exit(1)
+	}
+
+	// Only the default token file replaces the legacy one.
+	if configString("token_file") == "" && currentProfile() == "" {
+		err = migrateLegacyToken(tokenFile(), store)
+		if err != nil {
+			fmt.Printf("Error moving access token from %s: %v\n", legacyTokenPath, err)
+		}
 	}
 
-	var token Token
-	err = json.Unmarshal(data, &token)
-	return &token, err
+	return store
 }
 
-func refreshToken(clientID, clientSecret string, token *Token) (*Token, error) {
-	values := url.Values{}
-	values.Add("grant_type", "refresh_token")
-	values.Add("client_id", clientID)
-	values.Add("client_secret", clientSecret)
-	values.Add("refresh_token", token.RefreshToken)
-	body := strings.NewReader(values.Encode())
+// tokenPassphrase returns the passphrase used to encrypt the token, taken from
+// token_passphrase or the contents of token_key_file, or nil if neither is set.
+func tokenPassphrase() ([]byte, error) {
+	if passphrase := configString("token_passphrase"); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	keyFile := configString("token_key_file")
+	if keyFile == "" {
+		return nil, nil
+	}
 
-	resp, err := http.Post(tokenURL, "application/x-www-form-urlencoded", body)
+	data, err := ioutil.ReadFile(keyFile)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("%d status code when refreshing token", resp.StatusCode)
+	passphrase := bytes.TrimSpace(data)
+	if len(passphrase) == 0 {
+		msg := fmt.Sprintf("Key file %s is empty", keyFile)
 		return nil, errors.New(msg)
 	}
 
-	defer resp.Body.Close()
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	return passphrase, nil
+}
+
+// printTokenVars prints the environment variables read by the env and memory
+// token stores for token.
+func printTokenVars(token *monzo.Token) {
+	fmt.Printf("%s=%s\n", profileVar(accessTokenVar), token.AccessToken)
+	fmt.Printf("%s=%s\n", profileVar(refreshTokenVar), token.RefreshToken)
+}
+
+// profileVar returns the name of an environment variable for the current
+// profile, e.g. MONZO_ACCESS_TOKEN_ALICE.
+func profileVar(name string) string {
+	if profile := currentProfile(); profile != "" {
+		return name + "_" + strings.ToUpper(strings.Replace(profile, "-", "_", -1))
+	}
+	return name
+}
+
+// tokenFile returns the path of the token file, which is token.json (or
+// token-<profile>.json) in the configuration directory unless token_file is
+// set.
+func tokenFile() string {
+	if path := configString("token_file"); path != "" {
+		return path
 	}
 
-	var newToken Token
-	err = json.Unmarshal(respBody, &newToken)
+	dir, err := configDir()
 	if err != nil {
-		return nil, err
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
-	err = writeToken(&newToken)
+	if profile := currentProfile(); profile != "" {
+		return filepath.Join(dir, fmt.Sprintf("token-%s.json", profile))
+	}
 
-	return &newToken, err
+	return filepath.Join(dir, tokenFileName)
 }
 
-func writeToken(token *Token) error {
-	data, err := json.Marshal(token)
-	if err != nil {
-		return err
+// redirectURL returns the OAuth redirect URL, which is redirect_url if set and
+// the callback path on localhost otherwise.
+func redirectURL() string {
+	if redirect := configString("redirect_url"); redirect != "" {
+		return redirect
 	}
+	return fmt.Sprintf("http://localhost:%d%s", viper.GetInt("callback_port"), callbackPath)
+}
 
-	return ioutil.WriteFile(tokenPath, data, 0600)
+func tokenURL() string {
+	apiURL := strings.TrimSuffix(viper.GetString("api_url"), "/")
+	return apiURL + "/oauth2/token"
 }
 
 func runAuth(cmd *cobra.Command, args []string) {
-	clientID := viper.GetString("client_id")
-	clientSecret := viper.GetString("client_secret")
+	clientID := configString("client_id")
+	clientSecret := configString("client_secret")
+
+	state, err := generateState()
+	if err != nil {
+		fmt.Printf("Error generating state: %v\n", err)
+		os.Exit(1)
+	}
 
-	authURL := getAuthURL(clientID)
+	results := make(chan callbackResult, 1)
+	server, err := startCallbackServer(redirectURL(), state, results)
+	if err != nil {
+		fmt.Printf("Error starting callback server: %v\n", err)
+	} else {
+		defer server.Close()
+	}
+
+	authURL := getAuthURL(clientID, state)
 	fmt.Printf("Go to %s\n", authURL)
+	if server != nil {
+		fmt.Println("Waiting for the redirect, or paste the redirect URL here.")
+	}
+	fmt.Print("Redirect URL: ")
+
+	go func() {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" && server != nil {
+			return
+		}
 
-	var authCode string
-	fmt.Print("Authorization code: ")
-	fmt.Scanln(&authCode)
+		code, err := parsePastedRedirect(line, state)
+		select {
+		case results <- callbackResult{code, err, true}:
+		default:
+		}
+	}()
+
+	result := <-results
+	if !result.pasted {
+		fmt.Println()
+	}
+	if result.err != nil {
+		fmt.Printf("Error getting authorization code: %v\n", result.err)
+		os.Exit(1)
+	}
+	authCode := result.code
+
+	ctx, cancel := newContext(DefaultTimeout)
+	defer cancel()
 
 	fmt.Print("Getting access token... ")
-	token, err := getToken(clientID, clientSecret, authCode)
+	token, err := monzo.ExchangeCode(ctx, clientID, clientSecret, tokenURL(), redirectURL(), authCode)
 	if err != nil {
 		fmt.Println("ERROR")
 		fmt.Printf("Error getting access token: %v", err)
@@ -177,11 +346,34 @@ func runAuth(cmd *cobra.Command, args []string) {
 	fmt.Println("OK")
 
 	fmt.Print("Writing access token... ")
-	err = writeToken(token)
-	if err != nil {
+	err = newTokenStore().Save(token)
+	if err == monzo.ErrReadOnlyStore {
+		fmt.Println("SKIPPED")
+		fmt.Println("Token store is read-only, set these environment variables instead:")
+		printTokenVars(token)
+	} else if err != nil {
 		fmt.Println("ERROR")
 		fmt.Printf("Error writing access token: %v", err)
 		os.Exit(1)
+	} else {
+		fmt.Println("OK")
+	}
+
+	fmt.Println("Open the Monzo app and approve access for pennychallenge.")
+	fmt.Print("Waiting for approval... ")
+	approvalCtx, cancelApproval := newContext(viper.GetDuration("approval_timeout"))
+	defer cancelApproval()
+
+	client := monzo.NewClient(token.AccessToken, clientOptions()...)
+	err = waitForApproval(approvalCtx, client)
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Println("TIMEOUT")
+		fmt.Println("Access was not approved in time, approve it in the Monzo app before running other commands")
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Println("ERROR")
+		fmt.Printf("Error waiting for approval: %v\n", err)
+		os.Exit(1)
 	}
 	fmt.Println("OK")
 }
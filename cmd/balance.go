@@ -0,0 +1,90 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// balanceCmd represents the balance command
+var balanceCmd = &cobra.Command{
+	Use:   "balance",
+	Short: "Show Monzo account balance",
+	Long: `Shows the balance of a Monzo account, defaulting to the source account.
+Any of the balance fields can be used for the minimum balance check by setting
+balance_field.`,
+	Run: runBalance,
+}
+
+func init() {
+	rootCmd.AddCommand(balanceCmd)
+
+	balanceCmd.Flags().StringP("account", "a", "", "Account ID (default is the source account)")
+}
+
+func runBalance(cmd *cobra.Command, args []string) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Balance", "Total Balance", "Including Flexible Savings", "Spent Today"})
+
+	ctx, cancel := newContext(DefaultTimeout)
+	defer cancel()
+
+	client, err := newClient(ctx)
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	accountID, _ := cmd.Flags().GetString("account")
+	if accountID == "" {
+		accountID = configString("source_account")
+	}
+
+	account, err := getAccount(ctx, accountID, client)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	balance, err := client.Balance(ctx, account)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	table.Append([]string{
+		balance.Balance.String(),
+		balance.TotalBalance.String(),
+		balance.BalanceIncludingFlexibleSavings.String(),
+		balance.SpendToday.String(),
+	})
+
+	table.Render()
+}
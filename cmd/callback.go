@@ -0,0 +1,177 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const callbackPath = "/callback"
+
+var callbackPage = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html>
+<head><title>pennychallenge</title></head>
+<body style="font-family: sans-serif; text-align: center; margin-top: 4em">
+{{if .}}
+<h1>Authentication failed</h1>
+<p>{{.}}</p>
+<p>Return to the terminal and try again.</p>
+{{else}}
+<h1>Authentication successful</h1>
+<p>You can close this window and return to the terminal.</p>
+{{end}}
+</body>
+</html>
+`))
+
+type callbackResult struct {
+	code   string
+	err    error
+	pasted bool
+}
+
+// codeFromRedirect extracts the authorization code from the query of the
+// OAuth redirect URL, after checking that it carries the state sent in the
+// authorization URL.
+func codeFromRedirect(query url.Values, state string) (string, error) {
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		return "", errors.New("State in redirect does not match, not using authorization code")
+	}
+
+	if errCode := query.Get("error"); errCode != "" {
+		msg := fmt.Sprintf("Authorization failed: %s %s", errCode, query.Get("error_description"))
+		return "", errors.New(strings.TrimSpace(msg))
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		return "", errors.New("No authorization code in redirect")
+	}
+
+	return code, nil
+}
+
+// generateState returns a random value for the OAuth state parameter.
+func generateState() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// parsePastedRedirect extracts the authorization code from the redirect URL
+// copied from the browser. The full URL is needed so that the state can be
+// checked.
+func parsePastedRedirect(input, state string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", errors.New("No redirect URL entered")
+	}
+
+	redirect, err := url.Parse(input)
+	if err != nil {
+		return "", err
+	}
+
+	return codeFromRedirect(redirect.Query(), state)
+}
+
+// callbackAddress returns the address to listen on and the path to handle for
+// a redirect URL, which must point at localhost.
+func callbackAddress(redirect string) (string, string, error) {
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return "", "", err
+	}
+
+	host := u.Hostname()
+	if host != "localhost" && host != "127.0.0.1" {
+		msg := fmt.Sprintf("Redirect URL %s is not on localhost", redirect)
+		return "", "", errors.New(msg)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	return net.JoinHostPort(host, port), path, nil
+}
+
+// startCallbackServer listens on localhost for the OAuth redirect to redirect
+// and sends the result to results. The caller should close the returned server
+// once a result has been received.
+func startCallbackServer(redirect, state string, results chan<- callbackResult) (*http.Server, error) {
+	addr, path, err := callbackAddress(redirect)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		code, err := codeFromRedirect(r.URL.Query(), state)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			callbackPage.Execute(w, err.Error())
+		} else {
+			callbackPage.Execute(w, "")
+		}
+
+		select {
+		case results <- callbackResult{code, err, false}:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	return server, nil
+}
@@ -0,0 +1,88 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+	"github.com/spf13/cobra"
+)
+
+// logoutCmd represents the auth logout command
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Log out of Monzo",
+	Long: `Revokes the stored access token with the Monzo API and removes it.
+
+If the token can't be revoked, it is kept so that logging out can be retried.
+Use --force to remove it anyway.`,
+	Run: runLogout,
+}
+
+func init() {
+	authCmd.AddCommand(logoutCmd)
+
+	logoutCmd.Flags().Bool("force", false, "Remove the access token even if it can't be revoked")
+}
+
+func runLogout(cmd *cobra.Command, args []string) {
+	force, _ := cmd.Flags().GetBool("force")
+
+	ctx, cancel := newContext(DefaultTimeout)
+	defer cancel()
+
+	fmt.Print("Revoking access token... ")
+	client, err := newClient(ctx)
+	if err == nil {
+		err = client.Logout(ctx)
+	}
+	if err != nil {
+		fmt.Println("ERROR")
+		fmt.Printf("Error revoking access token: %v\n", err)
+		if !force {
+			fmt.Println("Access token kept, use --force to remove it anyway")
+			os.Exit(1)
+		}
+	} else {
+		fmt.Println("OK")
+	}
+
+	fmt.Print("Removing access token... ")
+	err = newTokenStore().Delete()
+	if err == monzo.ErrReadOnlyStore {
+		fmt.Println("SKIPPED")
+		fmt.Printf("Token store is read-only, unset %s and %s instead\n", profileVar(accessTokenVar), profileVar(refreshTokenVar))
+		return
+	}
+	if err != nil {
+		fmt.Println("ERROR")
+		fmt.Printf("Error removing access token: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
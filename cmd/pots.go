@@ -29,10 +29,8 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/jammystuff/pennychallenge/monzo"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // potsCmd represents the pots command
@@ -49,33 +47,40 @@ func init() {
 
 func runPots(cmd *cobra.Command, args []string) {
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"ID", "Name"})
+	table.SetHeader([]string{"ID", "Name", "Balance", "Goal", "Progress"})
 
-	t, err := readToken()
-	if err != nil {
-		fmt.Println("Error reading access token")
-		os.Exit(1)
-	}
+	ctx, cancel := newContext(DefaultTimeout)
+	defer cancel()
 
-	clientID := viper.GetString("client_id")
-	clientSecret := viper.GetString("client_secret")
-	refresh, err := refreshToken(clientID, clientSecret, t)
+	client, err := newClient(ctx)
 	if err != nil {
-		fmt.Printf("Error refreshing access token: %v\n", err)
+		fmt.Printf("Error getting access token: %v\n", err)
 		os.Exit(1)
 	}
 
-	token := refresh.AccessToken
-	client := monzo.NewClient(token)
-
-	pots, err := client.Pots()
+	pots, err := client.Pots(ctx)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
 	for _, pot := range *pots {
-		table.Append([]string{pot.ID, pot.Name})
+		if pot.Deleted {
+			continue
+		}
+
+		goal, progress := "", ""
+		if percent, ok := pot.GoalProgress(); ok {
+			goal = pot.GoalAmount.String()
+			progress = fmt.Sprintf("%.0f%%", percent)
+		}
+
+		name := pot.Name
+		if pot.Locked {
+			name += " (locked)"
+		}
+
+		table.Append([]string{pot.ID, name, pot.Balance.String(), goal, progress})
 	}
 
 	table.Render()
@@ -0,0 +1,77 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jammystuff/pennychallenge/strategy"
+	"github.com/spf13/cobra"
+)
+
+// previewCmd represents the preview command
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Show how much the configured strategy saves in a year",
+	Long: `Adds up the deposits the configured strategy, unit and offset would make over
+a calendar year without contacting the Monzo API, so the challenge can be sized
+before the first run.`,
+	Run: runPreview,
+}
+
+func init() {
+	rootCmd.AddCommand(previewCmd)
+
+	previewCmd.Flags().IntP("year", "y", time.Now().Year(), "Year to preview")
+}
+
+func runPreview(cmd *cobra.Command, args []string) {
+	year, _ := cmd.Flags().GetInt("year")
+
+	s, err := newStrategy("")
+	if err != nil {
+		fmt.Printf("Error creating strategy: %v\n", err)
+		os.Exit(1)
+	}
+
+	total, err := strategy.Total(context.Background(), s, year)
+	if err != nil {
+		fmt.Printf("Error calculating total: %v\n", err)
+		os.Exit(1)
+	}
+
+	name := configString("strategy")
+	if name == "" {
+		name = strategy.Default
+	}
+
+	fmt.Printf("Strategy:     %s\n", name)
+	fmt.Printf("Year:         %d\n", year)
+	fmt.Printf("Annual total: %s\n", total)
+}
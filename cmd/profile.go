@@ -0,0 +1,101 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// activeProfile is the profile being run by --all-profiles. It takes
+// precedence over --profile.
+var activeProfile string
+
+// changedFlags holds the names of the flags set on the command line.
+var changedFlags = map[string]bool{}
+
+// checkProfile returns an error if the selected profile isn't configured.
+func checkProfile() error {
+	name := currentProfile()
+	if name == "" || viper.IsSet("profiles."+name) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("Profile %s not found in config file", name)
+	return errors.New(msg)
+}
+
+// configString returns the value of key for the current profile. Flags given
+// on the command line take precedence, followed by the profile's setting and
+// then the top-level setting.
+func configString(key string) string {
+	name := currentProfile()
+	if name != "" && !changedFlags[strings.Replace(key, "_", "-", -1)] {
+		profileKey := fmt.Sprintf("profiles.%s.%s", name, key)
+		if viper.IsSet(profileKey) {
+			return viper.GetString(profileKey)
+		}
+	}
+
+	return viper.GetString(key)
+}
+
+// currentProfile returns the name of the selected profile in lower case, as
+// viper lower-cases the keys of the config file.
+func currentProfile() string {
+	if activeProfile != "" {
+		return activeProfile
+	}
+	return strings.ToLower(viper.GetString("profile"))
+}
+
+func profileNames() []string {
+	var names []string
+	for name := range viper.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// recordChangedFlags adds the flags of c and its subcommands that were set on
+// the command line to changedFlags.
+func recordChangedFlags(c *cobra.Command) {
+	c.Flags().Visit(func(f *pflag.Flag) {
+		changedFlags[f.Name] = true
+	})
+
+	for _, sub := range c.Commands() {
+		recordChangedFlags(sub)
+	}
+}
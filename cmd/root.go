@@ -26,21 +26,36 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/jammystuff/pennychallenge/monzo"
+	"github.com/jammystuff/pennychallenge/strategy"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 const (
-	DateFormat = "2006-01-02"
-	DaysInYear = 365
-	MinBalance = 1000
+	DefaultTimeout = 2 * time.Minute
+	MinBalance     = "£10.00"
+	UserAgent      = "pennychallenge"
+)
+
+// Exit codes used by the root command so that a scheduler can tell whether to
+// re-authenticate, retry later or simply wait for the next day.
+const (
+	ExitError        = 1
+	ExitSkipped      = 2
+	ExitUnauthorized = 3
+	ExitRateLimited  = 4
 )
 
 var cfgFile string
@@ -57,7 +72,23 @@ Monzo API to save the correct amount into a pot. This can then be run on a daily
 schedule using a functions as a service provider (e.g. Azure Functions).
 
 The advantage of the reversed penny challenge is that it avoids the maximum
-savings occurring during December, which is when spending is highest.`,
+savings occurring during December, which is when spending is highest. The
+classic penny challenge is available too using --strategy forward, as is the
+52-week challenge (saving £1 in the first week up to £52 in the last) using
+--strategy 52week or 52week-reversed. Amounts can be scaled with --unit and
+--offset, or set to add up to a yearly total with --target, and pennychallenge
+preview shows what the year will save.
+
+Several people can save from one config file using profiles, each with its own
+client credentials, token store, source account and destination pot:
+
+  profiles:
+    alice:
+      client_id: ...
+      source_account: ...
+      destination_pot: ...
+
+Select a profile with --profile, or save for all of them with --all-profiles.`,
 	Run: runRoot,
 }
 
@@ -73,19 +104,83 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 
+	viper.SetDefault("retry_max", monzo.DefaultRetryPolicy.MaxRetries)
+	viper.SetDefault("retry_min_backoff", monzo.DefaultRetryPolicy.MinBackoff)
+	viper.SetDefault("retry_max_backoff", monzo.DefaultRetryPolicy.MaxBackoff)
+
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pennychallenge.yaml)")
 
+	rootCmd.PersistentFlags().String("profile", "", "profile in the config file to use")
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+
+	rootCmd.PersistentFlags().String("token-file", "", "access token file (default is $XDG_CONFIG_HOME/pennychallenge/token.json)")
+	viper.BindPFlag("token_file", rootCmd.PersistentFlags().Lookup("token-file"))
+
+	rootCmd.PersistentFlags().String("token-store", "", "where to store the access token: file, encrypted, env or memory")
+	viper.BindPFlag("token_store", rootCmd.PersistentFlags().Lookup("token-store"))
+
+	rootCmd.PersistentFlags().String("token-key-file", "", "file containing the key used to encrypt the access token (or set TOKEN_PASSPHRASE)")
+	viper.BindPFlag("token_key_file", rootCmd.PersistentFlags().Lookup("token-key-file"))
+
 	rootCmd.Flags().StringP("source-account", "s", "", "Account ID to save from")
 	viper.BindPFlag("source_account", rootCmd.Flags().Lookup("source-account"))
 
 	rootCmd.Flags().StringP("destination-pot", "d", "", "Pot ID to save to")
 	viper.BindPFlag("destination_pot", rootCmd.Flags().Lookup("destination-pot"))
 
+	rootCmd.Flags().String("min-balance", MinBalance, "Minimum balance required to save")
+	viper.BindPFlag("min_balance", rootCmd.Flags().Lookup("min-balance"))
+
+	rootCmd.Flags().String("balance-field", "balance", "Balance field checked against the minimum balance (balance, total_balance or balance_including_flexible_savings)")
+	viper.BindPFlag("balance_field", rootCmd.Flags().Lookup("balance-field"))
+
+	rootCmd.PersistentFlags().String("strategy", strategy.Default, fmt.Sprintf("savings strategy (%s)", strings.Join(strategy.Names(), ", ")))
+	viper.BindPFlag("strategy", rootCmd.PersistentFlags().Lookup("strategy"))
+
+	rootCmd.PersistentFlags().String("weekday", "monday", "day of the week on which weekly strategies save, or the earliest if a run is missed")
+	viper.BindPFlag("weekday", rootCmd.PersistentFlags().Lookup("weekday"))
+
+	rootCmd.PersistentFlags().String("unit", "", "amount saved per step of the challenge, e.g. 0.05 (default 0.01, or 1.00 for weekly strategies)")
+	viper.BindPFlag("unit", rootCmd.PersistentFlags().Lookup("unit"))
+
+	rootCmd.PersistentFlags().String("offset", "", "fixed amount added to every deposit")
+	viper.BindPFlag("offset", rootCmd.PersistentFlags().Lookup("offset"))
+
+	rootCmd.PersistentFlags().String("target", "", "total to save over the year, scaling the penny challenge to match")
+	viper.BindPFlag("target", rootCmd.PersistentFlags().Lookup("target"))
+
+	rootCmd.Flags().Bool("all-profiles", false, "Save for every profile in the config file")
+	viper.BindPFlag("all_profiles", rootCmd.Flags().Lookup("all-profiles"))
+
+	rootCmd.Flags().Duration("timeout", DefaultTimeout, "Overall timeout for a run")
+	viper.BindPFlag("timeout", rootCmd.Flags().Lookup("timeout"))
+
 	rootCmd.PersistentFlags().StringP("client-id", "I", "", "Monzo API client ID")
 	viper.BindPFlag("client_id", rootCmd.PersistentFlags().Lookup("client-id"))
 
 	rootCmd.PersistentFlags().StringP("client-secret", "S", "", "Monzo API client secret")
 	viper.BindPFlag("client_secret", rootCmd.PersistentFlags().Lookup("client-secret"))
+
+	rootCmd.PersistentFlags().String("api-url", monzo.DefaultBaseURL, "Monzo API base URL")
+	viper.BindPFlag("api_url", rootCmd.PersistentFlags().Lookup("api-url"))
+
+	rootCmd.PersistentFlags().String("auth-url", defaultAuthURL, "Monzo authorization URL")
+	viper.BindPFlag("auth_url", rootCmd.PersistentFlags().Lookup("auth-url"))
+}
+
+// configDir returns the per-user configuration directory, following the XDG
+// base directory specification.
+func configDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "pennychallenge"), nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "pennychallenge"), nil
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -101,8 +196,11 @@ func initConfig() {
 			os.Exit(1)
 		}
 
-		// Search config in home directory with name ".pennychallenge" (without extension).
+		// Search config in home and configuration directories with name ".pennychallenge" (without extension).
 		viper.AddConfigPath(home)
+		if dir, err := configDir(); err == nil {
+			viper.AddConfigPath(dir)
+		}
 		viper.SetConfigName(".pennychallenge")
 	}
 
@@ -112,41 +210,78 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
+
+	recordChangedFlags(rootCmd)
+	if err := checkProfile(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
 
-func amountToSave(date time.Time) int64 {
-	days := int64(daysInYear(date))
-	yearDay := int64(date.YearDay())
-	return days + 1 - yearDay
+func balanceField(balance *monzo.Balance, field string) (monzo.Money, error) {
+	switch field {
+	case "", "balance":
+		return balance.Balance, nil
+	case "total_balance":
+		return balance.TotalBalance, nil
+	case "balance_including_flexible_savings":
+		return balance.BalanceIncludingFlexibleSavings, nil
+	}
+
+	msg := fmt.Sprintf("Unknown balance field %s", field)
+	return monzo.Money{}, errors.New(msg)
 }
 
-func checkBalance(account *monzo.Account, c *monzo.Client) (bool, error) {
-	balance, err := c.Balance(account)
+func checkBalance(ctx context.Context, account *monzo.Account, c *monzo.Client) (bool, error) {
+	balance, err := c.Balance(ctx, account)
+	if err != nil {
+		return false, err
+	}
+
+	amount, err := balanceField(balance, configString("balance_field"))
+	if err != nil {
+		return false, err
+	}
+
+	minBalance, err := monzo.ParseMoney(configString("min_balance"), balance.Currency)
+	if err != nil {
+		return false, err
+	}
+
+	cmp, err := amount.Cmp(minBalance)
 	if err != nil {
 		return false, err
 	}
 
-	if balance.Balance < MinBalance {
+	if cmp < 0 {
 		return false, nil
 	}
 
 	return true, nil
 }
 
-func daysInYear(date time.Time) int {
-	year := date.Year()
-	if year%4 != 0 {
-		return DaysInYear
-	} else if year%100 != 0 {
-		return DaysInYear + 1
-	} else if year%400 != 0 {
-		return DaysInYear
+// errorCode prints err and returns an exit code reflecting how the failure
+// should be handled.
+func errorCode(msg string, err error) int {
+	fmt.Printf("%s: %v\n", msg, err)
+
+	switch {
+	case monzo.IsUnauthorized(err):
+		fmt.Println("Access token rejected, run pennychallenge auth to re-authenticate")
+		return ExitUnauthorized
+	case monzo.IsRateLimited(err):
+		fmt.Println("Rate limited by the Monzo API, try again later")
+		return ExitRateLimited
+	case monzo.IsInsufficientFunds(err):
+		fmt.Println("Insufficient funds, skipping today's saving")
+		return ExitSkipped
 	}
-	return DaysInYear + 1
+
+	return ExitError
 }
 
-func getAccount(id string, c *monzo.Client) (*monzo.Account, error) {
-	accounts, err := c.Accounts()
+func getAccount(ctx context.Context, id string, c *monzo.Client) (*monzo.Account, error) {
+	accounts, err := c.Accounts(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -161,88 +296,235 @@ func getAccount(id string, c *monzo.Client) (*monzo.Account, error) {
 	return nil, errors.New(msg)
 }
 
-func savePennies(account *monzo.Account, pot *monzo.Pot, c *monzo.Client) error {
-	date := time.Now().UTC()
-	amount := amountToSave(date)
-	id := fmt.Sprintf("PENNY-%s", date.Format(DateFormat))
+// clientOptions returns the options for a Monzo API client using the
+// configured API URL and retry policy.
+func clientOptions() []monzo.Option {
+	retryPolicy := monzo.RetryPolicy{
+		MaxRetries: viper.GetInt("retry_max"),
+		MinBackoff: viper.GetDuration("retry_min_backoff"),
+		MaxBackoff: viper.GetDuration("retry_max_backoff"),
+	}
+
+	return []monzo.Option{
+		monzo.WithBaseURL(viper.GetString("api_url")),
+		monzo.WithUserAgent(UserAgent),
+		monzo.WithRetryPolicy(retryPolicy),
+	}
+}
+
+// newClient creates a Monzo API client that refreshes the stored access token
+// when it expires or is rejected.
+func newClient(ctx context.Context) (*monzo.Client, error) {
+	clientID := configString("client_id")
+	clientSecret := configString("client_secret")
+	store := newTokenStore()
+	source := monzo.NewRefreshTokenSource(clientID, clientSecret, tokenURL(), store)
+	switch store.(type) {
+	case *monzo.EnvTokenStore, *monzo.MemoryTokenStore:
+		// The refresh token in the environment is no longer valid, so the
+		// next run needs the new one.
+		source.OnRefresh = func(token *monzo.Token) {
+			fmt.Println()
+			fmt.Println("Access token refreshed, update these environment variables before the next run:")
+			printTokenVars(token)
+		}
+	}
+
+	_, err := source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	return c.DepositToPot(pot, account, amount, id)
+	opts := append(clientOptions(), monzo.WithTokenSource(source))
+	return monzo.NewClient("", opts...), nil
 }
 
-func getPot(id string, c *monzo.Client) (*monzo.Pot, error) {
-	pots, err := c.Pots()
+// newStrategy creates the configured savings strategy for a pot in currency. If
+// currency is empty, it is taken from the unit or target, or is the default
+// currency.
+func newStrategy(currency string) (strategy.Strategy, error) {
+	weekday, err := strategy.ParseWeekday(configString("weekday"))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := strategy.Options{Currency: currency, Weekday: weekday}
+	if unit := configString("unit"); unit != "" {
+		opts.Unit, err = monzo.ParseMoney(unit, currency)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Currency == "" {
+			opts.Currency = opts.Unit.Currency
+		}
+	}
+	if offset := configString("offset"); offset != "" {
+		opts.Offset, err = monzo.ParseMoney(offset, opts.Currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if target := configString("target"); target != "" {
+		opts.Target, err = monzo.ParseMoney(target, opts.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Currency == "" {
+			opts.Currency = opts.Target.Currency
+		}
+	}
+
+	return strategy.New(configString("strategy"), opts)
+}
+
+// savePennies deposits today's amount from the configured strategy into pot.
+// It returns false if the strategy has nothing to save today.
+func savePennies(ctx context.Context, account *monzo.Account, pot *monzo.Pot, c *monzo.Client) (bool, error) {
+	s, err := newStrategy(pot.Currency)
+	if err != nil {
+		return false, err
+	}
+
+	amount, id, err := s.Deposit(ctx, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	if amount.IsZero() {
+		return false, nil
+	}
+	if amount.Amount < 0 {
+		msg := fmt.Sprintf("Strategy returned a negative amount %s", amount)
+		return false, errors.New(msg)
+	}
+
+	return true, c.DepositToPot(ctx, pot, account, amount, id)
+}
+
+func getPot(ctx context.Context, id string, c *monzo.Client) (*monzo.Pot, error) {
+	pots, err := c.Pots(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, pot := range *pots {
-		if pot.ID == id {
-			return &pot, nil
+		if pot.ID != id {
+			continue
 		}
+
+		if pot.Deleted {
+			msg := fmt.Sprintf("Pot %s has been deleted", id)
+			return nil, errors.New(msg)
+		}
+		if pot.Locked {
+			msg := fmt.Sprintf("Pot %s is locked", id)
+			return nil, errors.New(msg)
+		}
+
+		return &pot, nil
 	}
 
 	msg := fmt.Sprintf("Pot %s not found", id)
 	return nil, errors.New(msg)
 }
 
+// newContext returns a context that is cancelled on SIGINT or SIGTERM and, if
+// timeout is positive, once timeout has elapsed.
+func newContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
 func runRoot(cmd *cobra.Command, args []string) {
-	t, err := readToken()
-	if err != nil {
-		fmt.Println("Error reading access token")
-		os.Exit(1)
+	if !viper.GetBool("all_profiles") {
+		os.Exit(runSave())
 	}
 
-	clientID := viper.GetString("client_id")
-	clientSecret := viper.GetString("client_secret")
-	refresh, err := refreshToken(clientID, clientSecret, t)
-	if err != nil {
-		fmt.Printf("Error refreshing access token: %v\n", err)
-		os.Exit(1)
+	names := profileNames()
+	if len(names) == 0 {
+		fmt.Println("No profiles found in config file")
+		os.Exit(ExitError)
 	}
 
-	token := refresh.AccessToken
-	client := monzo.NewClient(token)
+	code := 0
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Profile %s\n", name)
+
+		activeProfile = name
+		if c := runSave(); c != 0 && code == 0 {
+			code = c
+		}
+	}
+	activeProfile = ""
+
+	os.Exit(code)
+}
+
+// runSave saves today's pennies for the current profile and returns the exit
+// code for the run.
+func runSave() int {
+	ctx, cancel := newContext(viper.GetDuration("timeout"))
+	defer cancel()
+
+	client, err := newClient(ctx)
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		return ExitError
+	}
 
 	fmt.Print("Getting account... ")
-	accountID := viper.GetString("source_account")
-	account, err := getAccount(accountID, client)
+	accountID := configString("source_account")
+	account, err := getAccount(ctx, accountID, client)
 	if err != nil {
 		fmt.Println("ERROR")
-		fmt.Printf("Error getting account: %v\n", err)
-		os.Exit(1)
+		return errorCode("Error getting account", err)
 	}
 	fmt.Println("OK")
 
 	fmt.Print("Checking balance... ")
-	ok, err := checkBalance(account, client)
+	ok, err := checkBalance(ctx, account, client)
 	if err != nil {
 		fmt.Println("ERROR")
-		fmt.Printf("Error checking balance: %v\n", err)
-		os.Exit(1)
+		return errorCode("Error checking balance", err)
 	}
 	if !ok {
 		fmt.Println("FAIL")
 		fmt.Println("Account balance too low")
-		os.Exit(2)
+		return ExitSkipped
 	}
 	fmt.Println("OK")
 
 	fmt.Print("Getting pot... ")
-	potID := viper.GetString("destination_pot")
-	pot, err := getPot(potID, client)
+	potID := configString("destination_pot")
+	pot, err := getPot(ctx, potID, client)
 	if err != nil {
 		fmt.Println("ERROR")
-		fmt.Printf("Error getting pot: %v\n", err)
-		os.Exit(1)
+		return errorCode("Error getting pot", err)
 	}
 	fmt.Println("OK")
 
 	fmt.Print("Saving... ")
-	err = savePennies(account, pot, client)
+	saved, err := savePennies(ctx, account, pot, client)
 	if err != nil {
 		fmt.Println("ERROR")
-		fmt.Printf("Error saving: %v\n", err)
-		os.Exit(1)
+		return errorCode("Error saving", err)
+	}
+	if !saved {
+		fmt.Println("SKIPPED")
+		fmt.Println("Nothing to save today")
+		return ExitSkipped
 	}
 	fmt.Println("OK")
+
+	return 0
 }
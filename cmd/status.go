@@ -0,0 +1,83 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the auth status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show authentication status",
+	Long: `Checks the stored access token with the Monzo API and shows the user and
+client it belongs to and when it expires. The token is only refreshed when it is
+close to expiry.`,
+	Run: runStatus,
+}
+
+func init() {
+	authCmd.AddCommand(statusCmd)
+}
+
+func runStatus(cmd *cobra.Command, args []string) {
+	ctx, cancel := newContext(DefaultTimeout)
+	defer cancel()
+
+	client, err := newClient(ctx)
+	if err != nil {
+		fmt.Printf("Not authenticated: %v\n", err)
+		os.Exit(1)
+	}
+
+	whoAmI, err := client.WhoAmI(ctx)
+	if err != nil {
+		fmt.Printf("Error checking access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Authenticated: %t\n", whoAmI.Authenticated)
+	fmt.Printf("User ID:       %s\n", whoAmI.UserID)
+	fmt.Printf("Client ID:     %s\n", whoAmI.ClientID)
+
+	token, err := newTokenStore().Load()
+	if err != nil || token.Expiry.IsZero() {
+		fmt.Println("Expires:       unknown (refreshed when rejected)")
+		return
+	}
+
+	remaining := time.Until(token.Expiry).Round(time.Second)
+	if remaining <= 0 {
+		fmt.Printf("Expires:       %s (expired, refreshed on next run)\n", token.Expiry.Local().Format(time.RFC1123))
+		return
+	}
+
+	fmt.Printf("Expires:       %s (in %s)\n", token.Expiry.Local().Format(time.RFC1123), remaining)
+}
@@ -0,0 +1,113 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+	"github.com/spf13/cobra"
+)
+
+// withdrawCmd represents the pots withdraw command
+var withdrawCmd = &cobra.Command{
+	Use:   "withdraw",
+	Short: "Withdraw from a Monzo pot",
+	Long: `Withdraws an amount from a Monzo pot back into an account.
+This can be used to correct a mistaken deposit.`,
+	Run: runWithdraw,
+}
+
+func init() {
+	potsCmd.AddCommand(withdrawCmd)
+
+	withdrawCmd.Flags().StringP("pot", "p", "", "Pot ID to withdraw from")
+	withdrawCmd.Flags().StringP("account", "a", "", "Account ID to withdraw to")
+	withdrawCmd.Flags().StringP("amount", "m", "", "Amount to withdraw, e.g. £10 or 10.50")
+	withdrawCmd.Flags().String("dedupe-id", "", "Dedupe ID for the withdrawal (default is generated)")
+	withdrawCmd.MarkFlagRequired("pot")
+	withdrawCmd.MarkFlagRequired("account")
+	withdrawCmd.MarkFlagRequired("amount")
+}
+
+func runWithdraw(cmd *cobra.Command, args []string) {
+	dedupeID, _ := cmd.Flags().GetString("dedupe-id")
+	if dedupeID == "" {
+		dedupeID = fmt.Sprintf("WITHDRAW-%d", time.Now().UnixNano())
+	}
+
+	ctx, cancel := newContext(DefaultTimeout)
+	defer cancel()
+
+	client, err := newClient(ctx)
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print("Getting account... ")
+	accountID, _ := cmd.Flags().GetString("account")
+	account, err := getAccount(ctx, accountID, client)
+	if err != nil {
+		fmt.Println("ERROR")
+		fmt.Printf("Error getting account: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+
+	fmt.Print("Getting pot... ")
+	potID, _ := cmd.Flags().GetString("pot")
+	pot, err := getPot(ctx, potID, client)
+	if err != nil {
+		fmt.Println("ERROR")
+		fmt.Printf("Error getting pot: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+
+	// Amounts without a currency are in the currency of the pot.
+	amountStr, _ := cmd.Flags().GetString("amount")
+	amount, err := monzo.ParseMoney(amountStr, pot.Currency)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if amount.Amount <= 0 {
+		fmt.Println("Amount must be greater than zero")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Withdrawing %s... ", amount)
+	err = client.WithdrawFromPot(ctx, pot, account, amount, dedupeID)
+	if err != nil {
+		fmt.Println("ERROR")
+		fmt.Printf("Error withdrawing: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
@@ -25,7 +25,36 @@
 
 package monzo
 
+import "encoding/json"
+
 type Balance struct {
-	Balance  int64
-	Currency string
+	Balance                         Money
+	TotalBalance                    Money `json:"total_balance"`
+	BalanceIncludingFlexibleSavings Money `json:"balance_including_flexible_savings"`
+	Currency                        string
+	SpendToday                      Money `json:"spend_today"`
+}
+
+// UnmarshalJSON decodes the amounts, which the API sends in minor units, into
+// Money in the balance currency.
+func (b *Balance) UnmarshalJSON(data []byte) error {
+	type balance Balance
+	v := struct {
+		*balance
+		Balance                         int64
+		TotalBalance                    int64 `json:"total_balance"`
+		BalanceIncludingFlexibleSavings int64 `json:"balance_including_flexible_savings"`
+		SpendToday                      int64 `json:"spend_today"`
+	}{balance: (*balance)(b)}
+
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	b.Balance = NewMoney(v.Balance, b.Currency)
+	b.TotalBalance = NewMoney(v.TotalBalance, b.Currency)
+	b.BalanceIncludingFlexibleSavings = NewMoney(v.BalanceIncludingFlexibleSavings, b.Currency)
+	b.SpendToday = NewMoney(v.SpendToday, b.Currency)
+	return nil
 }
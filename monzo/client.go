@@ -26,46 +26,64 @@
 package monzo
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
-const baseURL = "https://api.monzo.com"
+const DefaultBaseURL = "https://api.monzo.com"
+
+// DefaultTimeout bounds each HTTP request made by a Client, so that a hung
+// connection cannot block a caller forever.
+const DefaultTimeout = 30 * time.Second
+
+// TransactionPageSize is the largest number of transactions the API returns
+// in a single page.
+const TransactionPageSize = 100
 
 type Client struct {
 	httpClient  *http.Client
 	accessToken string
+	baseURL     string
+	userAgent   string
+	retryPolicy RetryPolicy
+	tokenSource TokenSource
 }
 
-func NewClient(accessToken string) *Client {
-	return &Client{
-		httpClient:  &http.Client{},
+func NewClient(accessToken string, opts ...Option) *Client {
+	c := &Client{
+		httpClient:  &http.Client{Timeout: DefaultTimeout},
 		accessToken: accessToken,
+		baseURL:     DefaultBaseURL,
+		retryPolicy: DefaultRetryPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
-func (c *Client) Accounts() (*[]Account, error) {
-	req, err := http.NewRequest("GET", baseURL+"/accounts", nil)
+func (c *Client) Accounts(ctx context.Context) (*[]Account, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/accounts", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("Authorization", "Bearer "+c.accessToken)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("/accounts returned %d status code", resp.StatusCode)
-		return nil, errors.New(msg)
+		return nil, newAPIError("/accounts", resp)
 	}
 
 	defer resp.Body.Close()
@@ -83,23 +101,20 @@ func (c *Client) Accounts() (*[]Account, error) {
 	return &accountList.Accounts, nil
 }
 
-func (c *Client) Balance(account *Account) (*Balance, error) {
-	url := fmt.Sprintf("%s/balance?account_id=%s", baseURL, account.ID)
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Client) Balance(ctx context.Context, account *Account) (*Balance, error) {
+	url := fmt.Sprintf("%s/balance?account_id=%s", c.baseURL, account.ID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("Authorization", "Bearer "+c.accessToken)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("/balance returned %d status code", resp.StatusCode)
-		return nil, errors.New(msg)
+		return nil, newAPIError("/balance", resp)
 	}
 
 	defer resp.Body.Close()
@@ -117,51 +132,115 @@ func (c *Client) Balance(account *Account) (*Balance, error) {
 	return &balance, nil
 }
 
-func (c *Client) DepositToPot(pot *Pot, source *Account, amount int64, id string) error {
+func (c *Client) DepositToPot(ctx context.Context, pot *Pot, source *Account, amount Money, id string) error {
+	if pot.Currency != "" && amount.Currency != pot.Currency {
+		return currencyMismatch(amount, pot.Balance)
+	}
+
 	values := url.Values{}
 	values.Add("source_account_id", source.ID)
-	values.Add("amount", strconv.FormatInt(amount, 10))
+	values.Add("amount", strconv.FormatInt(amount.Amount, 10))
 	values.Add("dedupe_id", id)
 	body := strings.NewReader(values.Encode())
 
-	url := fmt.Sprintf("%s/pots/%s/deposit", baseURL, pot.ID)
-	req, err := http.NewRequest("PUT", url, body)
+	url := fmt.Sprintf("%s/pots/%s/deposit", c.baseURL, pot.ID)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, body)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Add("Authorization", "Bearer "+c.accessToken)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("/pots/deposit returned %d status code", resp.StatusCode)
-		return errors.New(msg)
+		return newAPIError("/pots/deposit", resp)
 	}
 
+	resp.Body.Close()
 	return nil
 }
 
-func (c *Client) Pots() (*[]Pot, error) {
-	req, err := http.NewRequest("GET", baseURL+"/pots", nil)
+// do sends req with the current access token. If the API rejects the token and
+// the client has a TokenSource, the token is refreshed and the request is sent
+// once more.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	accessToken := c.accessToken
+	if c.tokenSource != nil {
+		token, err := c.tokenSource.Token(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		accessToken = token.AccessToken
+	}
+
+	c.setHeaders(req, accessToken)
+	resp, err := c.doWithRetries(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || c.tokenSource == nil {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	token, err := c.tokenSource.Refresh(req.Context())
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Add("Authorization", "Bearer "+c.accessToken)
+	err = rewindBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setHeaders(req, token.AccessToken)
+	return c.doWithRetries(req)
+}
+
+func (c *Client) IterateTransactions(ctx context.Context, accountID, since string, before time.Time) *TransactionIterator {
+	return &TransactionIterator{
+		ctx:       ctx,
+		client:    c,
+		accountID: accountID,
+		since:     since,
+		before:    before,
+	}
+}
+
+// Logout revokes the client's access and refresh tokens.
+func (c *Client) Logout(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/oauth2/logout", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError("/oauth2/logout", resp)
+	}
+
+	resp.Body.Close()
+	return nil
+}
 
-	resp, err := c.httpClient.Do(req)
+func (c *Client) Pots(ctx context.Context) (*[]Pot, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/pots", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("/pots returned %d status code", resp.StatusCode)
-		return nil, errors.New(msg)
+		return nil, newAPIError("/pots", resp)
 	}
 
 	defer resp.Body.Close()
@@ -178,3 +257,119 @@ func (c *Client) Pots() (*[]Pot, error) {
 
 	return &potList.Pots, nil
 }
+
+func (c *Client) setHeaders(req *http.Request, accessToken string) {
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+}
+
+// Transactions lists the transactions of an account. since may be either an
+// RFC 3339 timestamp or a transaction ID, and is omitted when empty, as are a
+// zero before and a zero limit.
+func (c *Client) Transactions(ctx context.Context, accountID, since string, before time.Time, limit int) (*[]Transaction, error) {
+	values := url.Values{}
+	values.Add("account_id", accountID)
+	values.Add("expand[]", "merchant")
+	if since != "" {
+		values.Add("since", since)
+	}
+	if !before.IsZero() {
+		values.Add("before", before.UTC().Format(time.RFC3339))
+	}
+	if limit > 0 {
+		values.Add("limit", strconv.Itoa(limit))
+	}
+
+	url := fmt.Sprintf("%s/transactions?%s", c.baseURL, values.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("/transactions", resp)
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactionList TransactionList
+	err = json.Unmarshal(body, &transactionList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transactionList.Transactions, nil
+}
+
+func (c *Client) WithdrawFromPot(ctx context.Context, pot *Pot, destination *Account, amount Money, id string) error {
+	if pot.Currency != "" && amount.Currency != pot.Currency {
+		return currencyMismatch(amount, pot.Balance)
+	}
+
+	values := url.Values{}
+	values.Add("destination_account_id", destination.ID)
+	values.Add("amount", strconv.FormatInt(amount.Amount, 10))
+	values.Add("dedupe_id", id)
+	body := strings.NewReader(values.Encode())
+
+	url := fmt.Sprintf("%s/pots/%s/withdraw", c.baseURL, pot.ID)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError("/pots/withdraw", resp)
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) WhoAmI(ctx context.Context) (*WhoAmI, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/ping/whoami", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("/ping/whoami", resp)
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var whoAmI WhoAmI
+	err = json.Unmarshal(body, &whoAmI)
+	if err != nil {
+		return nil, err
+	}
+
+	return &whoAmI, nil
+}
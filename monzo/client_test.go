@@ -0,0 +1,162 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monzo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+	"github.com/jammystuff/pennychallenge/monzotest"
+)
+
+var testRetryPolicy = monzo.RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: time.Millisecond,
+	MaxBackoff: 10 * time.Millisecond,
+}
+
+func newTestServer(t *testing.T) *monzotest.Server {
+	server := monzotest.NewServer()
+	t.Cleanup(server.Close)
+
+	server.AddAccount(monzo.Account{ID: "acc_1"}, 10000)
+	server.AddPot(monzo.Pot{ID: "pot_1", Name: "Pennies"})
+	return server
+}
+
+func newTestClient(server *monzotest.Server) *monzo.Client {
+	return monzo.NewClient(monzotest.AccessToken,
+		monzo.WithBaseURL(server.URL),
+		monzo.WithRetryPolicy(testRetryPolicy))
+}
+
+func deposit(t *testing.T, client *monzo.Client, amount int64, id string) {
+	t.Helper()
+
+	ctx := context.Background()
+	pots, err := client.Pots(ctx)
+	if err != nil {
+		t.Fatalf("Pots: %v", err)
+	}
+
+	account := &monzo.Account{ID: "acc_1"}
+	err = client.DepositToPot(ctx, &(*pots)[0], account, monzo.NewMoney(amount, "GBP"), id)
+	if err != nil {
+		t.Fatalf("DepositToPot: %v", err)
+	}
+}
+
+func TestClientRefreshesRejectedToken(t *testing.T) {
+	server := newTestServer(t)
+
+	store := monzo.NewMemoryTokenStore(&monzo.Token{
+		AccessToken:  "stale",
+		RefreshToken: monzotest.RefreshToken,
+	})
+	source := monzo.NewRefreshTokenSource(monzotest.ClientID, monzotest.ClientSecret, server.URL+"/oauth2/token", store)
+	client := monzo.NewClient("", monzo.WithBaseURL(server.URL), monzo.WithTokenSource(source))
+
+	accounts, err := client.Accounts(context.Background())
+	if err != nil {
+		t.Fatalf("Accounts: %v", err)
+	}
+	if len(*accounts) != 1 {
+		t.Errorf("got %d accounts, want 1", len(*accounts))
+	}
+
+	token, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	accessToken, refreshToken := server.Token()
+	if token.AccessToken != accessToken || token.RefreshToken != refreshToken {
+		t.Errorf("stored token %s/%s, want rotated token %s/%s", token.AccessToken, token.RefreshToken, accessToken, refreshToken)
+	}
+	if refreshToken == monzotest.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+}
+
+func TestClientReportsRefreshedToken(t *testing.T) {
+	server := newTestServer(t)
+
+	t.Setenv("TEST_ACCESS_TOKEN", "stale")
+	t.Setenv("TEST_REFRESH_TOKEN", monzotest.RefreshToken)
+	store := &monzo.EnvTokenStore{AccessTokenVar: "TEST_ACCESS_TOKEN", RefreshTokenVar: "TEST_REFRESH_TOKEN"}
+	source := monzo.NewRefreshTokenSource(monzotest.ClientID, monzotest.ClientSecret, server.URL+"/oauth2/token", store)
+
+	var refreshed *monzo.Token
+	source.OnRefresh = func(token *monzo.Token) {
+		refreshed = token
+	}
+
+	client := monzo.NewClient("", monzo.WithBaseURL(server.URL), monzo.WithTokenSource(source))
+	_, err := client.Accounts(context.Background())
+	if err != nil {
+		t.Fatalf("Accounts: %v", err)
+	}
+
+	if refreshed == nil {
+		t.Fatal("OnRefresh was not called")
+	}
+	_, refreshToken := server.Token()
+	if refreshed.RefreshToken != refreshToken {
+		t.Errorf("OnRefresh got refresh token %s, want %s", refreshed.RefreshToken, refreshToken)
+	}
+}
+
+func TestClientRetriesFailedDeposit(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(server)
+
+	server.FailNext("/pots/deposit", 503, "internal_service")
+	deposit(t, client, 123, "PENNY-2026-01-01")
+
+	if got := server.PotBalance("pot_1"); got != 123 {
+		t.Errorf("pot balance %d, want 123", got)
+	}
+	if got := server.Balance("acc_1"); got != 10000-123 {
+		t.Errorf("account balance %d, want %d", got, 10000-123)
+	}
+}
+
+func TestClientDuplicateDepositIsNoOp(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(server)
+
+	deposit(t, client, 123, "PENNY-2026-01-01")
+	deposit(t, client, 123, "PENNY-2026-01-01")
+
+	if got := server.PotBalance("pot_1"); got != 123 {
+		t.Errorf("pot balance %d, want 123", got)
+	}
+	if got := server.Balance("acc_1"); got != 10000-123 {
+		t.Errorf("account balance %d, want %d", got, 10000-123)
+	}
+}
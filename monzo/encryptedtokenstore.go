@@ -0,0 +1,167 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monzo
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	encryptedTokenVersion = 1
+	saltSize              = 16
+)
+
+// EncryptedFileTokenStore stores a token in a file encrypted with AES-256-GCM,
+// using a key derived from Passphrase with scrypt. A new salt and nonce are
+// generated each time the token is saved. A plaintext token file, as written
+// by FileTokenStore, is encrypted as soon as it is loaded.
+type EncryptedFileTokenStore struct {
+	Path       string
+	Passphrase []byte
+}
+
+type encryptedToken struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (s *EncryptedFileTokenStore) Delete() error {
+	return deleteFile(s.Path)
+}
+
+func (s *EncryptedFileTokenStore) Load() (*Token, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var encrypted encryptedToken
+	err = json.Unmarshal(data, &encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	if encrypted.Version == 0 {
+		var token Token
+		err = json.Unmarshal(data, &token)
+		if err != nil {
+			return nil, err
+		}
+
+		// Don't leave the token in plaintext until it is next refreshed.
+		return &token, s.Save(&token)
+	}
+	if encrypted.Version != encryptedTokenVersion {
+		return nil, errors.New("Unsupported encrypted token version")
+	}
+
+	aead, err := s.cipher(encrypted.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encrypted.Nonce) != aead.NonceSize() {
+		return nil, errors.New("Invalid encrypted token nonce")
+	}
+
+	plaintext, err := aead.Open(nil, encrypted.Nonce, encrypted.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("Unable to decrypt token, check the passphrase or key file")
+	}
+
+	var token Token
+	err = json.Unmarshal(plaintext, &token)
+	return &token, err
+}
+
+// Lock takes an advisory lock on the token file. See TokenLocker.
+func (s *EncryptedFileTokenStore) Lock(ctx context.Context) (func(), error) {
+	return lockFile(ctx, s.Path+".lock")
+}
+
+func (s *EncryptedFileTokenStore) Save(token *Token) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return err
+	}
+
+	aead, err := s.cipher(salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(encryptedToken{
+		Version:    encryptedTokenVersion,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.Path, data, 0600)
+}
+
+func (s *EncryptedFileTokenStore) cipher(salt []byte) (cipher.AEAD, error) {
+	if len(s.Passphrase) == 0 {
+		return nil, errors.New("No passphrase for encrypted token")
+	}
+
+	key, err := scrypt.Key(s.Passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
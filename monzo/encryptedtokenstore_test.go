@@ -0,0 +1,75 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monzo_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+)
+
+func TestEncryptedFileTokenStoreEncryptsPlaintextToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	token := &monzo.Token{AccessToken: "access", RefreshToken: "refresh"}
+
+	err := (&monzo.FileTokenStore{Path: path}).Save(token)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	store := &monzo.EncryptedFileTokenStore{Path: path, Passphrase: []byte("secret")}
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.AccessToken != "access" || loaded.RefreshToken != "refresh" {
+		t.Errorf("loaded token %s/%s, want access/refresh", loaded.AccessToken, loaded.RefreshToken)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if bytes.Contains(data, []byte("refresh")) {
+		t.Errorf("token file still contains the plaintext token: %s", data)
+	}
+
+	loaded, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load after encrypting: %v", err)
+	}
+	if loaded.RefreshToken != "refresh" {
+		t.Errorf("loaded refresh token %s, want refresh", loaded.RefreshToken)
+	}
+
+	wrong := &monzo.EncryptedFileTokenStore{Path: path, Passphrase: []byte("wrong")}
+	if _, err := wrong.Load(); err == nil {
+		t.Error("Load with the wrong passphrase succeeded")
+	}
+}
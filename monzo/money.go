@@ -0,0 +1,254 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monzo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is assumed when parsing amounts that don't name a currency.
+const DefaultCurrency = "GBP"
+
+type currency struct {
+	code     string
+	symbol   string
+	exponent int
+}
+
+var currencies = []currency{
+	{"GBP", "£", 2},
+	{"EUR", "€", 2},
+	{"USD", "$", 2},
+	{"JPY", "¥", 0},
+}
+
+func lookupCurrency(code string) currency {
+	for _, c := range currencies {
+		if c.code == code {
+			return c
+		}
+	}
+	return currency{code: code, exponent: 2}
+}
+
+// Money is an amount in the minor units of an ISO 4217 currency, e.g. pence
+// for GBP.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses user input such as "£10", "10.50", "-1.23" or "5 EUR". The
+// currency is taken from a symbol or code in s, or is currency otherwise.
+// Commas are only accepted as thousands separators, e.g. "£1,000.00".
+func ParseMoney(s, currency string) (Money, error) {
+	input := s
+	s = strings.TrimSpace(s)
+
+	negative := false
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = strings.TrimSpace(s[1:])
+	}
+
+	if rest, code, ok := trimCurrency(s); ok {
+		s, currency = rest, code
+	} else if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	exponent := lookupCurrency(currency).exponent
+	whole, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	whole, ok := trimThousands(whole)
+	if !ok || whole == "" && fraction == "" || len(fraction) > exponent ||
+		strings.Trim(whole, "0123456789") != "" || strings.Trim(fraction, "0123456789") != "" {
+		msg := fmt.Sprintf("Invalid amount %q", input)
+		return Money{}, errors.New(msg)
+	}
+
+	digits := whole + fraction + strings.Repeat("0", exponent-len(fraction))
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid amount %q", input)
+		return Money{}, errors.New(msg)
+	}
+
+	if negative {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, currencyMismatch(m, o)
+	}
+
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, errors.New("Amount overflow")
+	}
+
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Cmp returns -1, 0 or 1 if m is less than, equal to or greater than o.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, currencyMismatch(m, o)
+	}
+
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Amount, m.Currency})
+}
+
+func (m Money) Mul(n int64) (Money, error) {
+	product := m.Amount * n
+	if n != 0 && (product/n != m.Amount || (n == -1 && m.Amount == math.MinInt64)) {
+		return Money{}, errors.New("Amount overflow")
+	}
+
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// String formats m using the currency symbol where known, e.g. "£1.23" or
+// "-€0.50", and the currency code otherwise, e.g. "1.23 CHF".
+func (m Money) String() string {
+	c := lookupCurrency(m.Currency)
+
+	sign := ""
+	amount := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		amount = uint64(-m.Amount)
+	}
+
+	digits := strconv.FormatUint(amount, 10)
+	if c.exponent > 0 {
+		if len(digits) <= c.exponent {
+			digits = strings.Repeat("0", c.exponent-len(digits)+1) + digits
+		}
+		point := len(digits) - c.exponent
+		digits = digits[:point] + "." + digits[point:]
+	}
+
+	if c.symbol == "" {
+		return fmt.Sprintf("%s%s %s", sign, digits, m.Currency)
+	}
+	return sign + c.symbol + digits
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, errors.New("Amount overflow")
+	}
+
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	m.Amount = v.Amount
+	m.Currency = v.Currency
+	return nil
+}
+
+// trimThousands removes thousands separators from whole, which must separate
+// groups of three digits, e.g. "1,000,000". It returns false if the commas are
+// anywhere else, as in "10,50", which is likely a decimal comma.
+func trimThousands(whole string) (string, bool) {
+	groups := strings.Split(whole, ",")
+	if len(groups) == 1 {
+		return whole, true
+	}
+
+	if len(groups[0]) == 0 || len(groups[0]) > 3 {
+		return "", false
+	}
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return "", false
+		}
+	}
+
+	return strings.Join(groups, ""), true
+}
+
+// trimCurrency removes the first known currency symbol prefix or code suffix
+// from s, returning the rest of s and the currency code.
+func trimCurrency(s string) (string, string, bool) {
+	for _, c := range currencies {
+		if strings.HasPrefix(s, c.symbol) {
+			return strings.TrimSpace(strings.TrimPrefix(s, c.symbol)), c.code, true
+		}
+		if strings.HasSuffix(s, c.code) {
+			return strings.TrimSpace(strings.TrimSuffix(s, c.code)), c.code, true
+		}
+	}
+	return s, "", false
+}
+
+func currencyMismatch(m, o Money) error {
+	msg := fmt.Sprintf("Currency mismatch: %s and %s", m.Currency, o.Currency)
+	return errors.New(msg)
+}
@@ -0,0 +1,134 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monzo_test
+
+import (
+	"testing"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		want     monzo.Money
+		wantErr  bool
+	}{
+		{"10", "", monzo.NewMoney(1000, "GBP"), false},
+		{"10.5", "", monzo.NewMoney(1050, "GBP"), false},
+		{"0.01", "GBP", monzo.NewMoney(1, "GBP"), false},
+		{".50", "GBP", monzo.NewMoney(50, "GBP"), false},
+		{"£10", "", monzo.NewMoney(1000, "GBP"), false},
+		{" £10.50 ", "", monzo.NewMoney(1050, "GBP"), false},
+		{"-£1.5", "", monzo.NewMoney(-150, "GBP"), false},
+		{"-1.23", "GBP", monzo.NewMoney(-123, "GBP"), false},
+		{"€10", "GBP", monzo.NewMoney(1000, "EUR"), false},
+		{"5 EUR", "", monzo.NewMoney(500, "EUR"), false},
+		{"5EUR", "GBP", monzo.NewMoney(500, "EUR"), false},
+		{"$2.99", "", monzo.NewMoney(299, "USD"), false},
+		{"¥100", "", monzo.NewMoney(100, "JPY"), false},
+		{"100", "JPY", monzo.NewMoney(100, "JPY"), false},
+		{"1.5", "CHF", monzo.NewMoney(150, "CHF"), false},
+		{"1,000", "", monzo.NewMoney(100000, "GBP"), false},
+		{"£1,000.00", "", monzo.NewMoney(100000, "GBP"), false},
+		{"1,234,567.89", "", monzo.NewMoney(123456789, "GBP"), false},
+		{"92233720368547758.07", "GBP", monzo.NewMoney(9223372036854775807, "GBP"), false},
+
+		{"", "", monzo.Money{}, true},
+		{"£", "", monzo.Money{}, true},
+		{"-", "", monzo.Money{}, true},
+		{"abc", "", monzo.Money{}, true},
+		{"1.2.3", "", monzo.Money{}, true},
+		{"1.234", "GBP", monzo.Money{}, true},
+		{"1.5", "JPY", monzo.Money{}, true},
+		{"£5 EUR", "", monzo.Money{}, true},
+		{"--5", "", monzo.Money{}, true},
+		{"10,50", "", monzo.Money{}, true},
+		{"€10,50", "", monzo.Money{}, true},
+		{"1,00", "", monzo.Money{}, true},
+		{"1000,000", "", monzo.Money{}, true},
+		{",100", "", monzo.Money{}, true},
+		{"1,000,", "", monzo.Money{}, true},
+		{"1.000,00", "", monzo.Money{}, true},
+		{"92233720368547758.08", "GBP", monzo.Money{}, true},
+		{"99999999999999999999", "", monzo.Money{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := monzo.ParseMoney(test.input, test.currency)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q, %q) = %v, want error", test.input, test.currency, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %q): %v", test.input, test.currency, err)
+		} else if got != test.want {
+			t.Errorf("ParseMoney(%q, %q) = %#v, want %#v", test.input, test.currency, got, test.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money monzo.Money
+		want  string
+	}{
+		{monzo.NewMoney(0, "GBP"), "£0.00"},
+		{monzo.NewMoney(1, "GBP"), "£0.01"},
+		{monzo.NewMoney(123, "GBP"), "£1.23"},
+		{monzo.NewMoney(100000, "GBP"), "£1000.00"},
+		{monzo.NewMoney(-50, "EUR"), "-€0.50"},
+		{monzo.NewMoney(299, "USD"), "$2.99"},
+		{monzo.NewMoney(100, "JPY"), "¥100"},
+		{monzo.NewMoney(-5, "JPY"), "-¥5"},
+		{monzo.NewMoney(123, "CHF"), "1.23 CHF"},
+		{monzo.NewMoney(-9223372036854775808, "GBP"), "-£92233720368547758.08"},
+	}
+
+	for _, test := range tests {
+		if got := test.money.String(); got != test.want {
+			t.Errorf("%#v.String() = %q, want %q", test.money, got, test.want)
+		}
+	}
+}
+
+func TestMoneyOverflow(t *testing.T) {
+	max := monzo.NewMoney(9223372036854775807, "GBP")
+
+	if _, err := max.Add(monzo.NewMoney(1, "GBP")); err == nil {
+		t.Error("Add overflowed without an error")
+	}
+	if _, err := max.Mul(2); err == nil {
+		t.Error("Mul overflowed without an error")
+	}
+	if _, err := max.Add(monzo.NewMoney(1, "EUR")); err == nil {
+		t.Error("Add of different currencies succeeded")
+	}
+}
@@ -25,9 +25,53 @@
 
 package monzo
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Pot struct {
-	ID   string
-	Name string
+	ID         string
+	Name       string
+	Style      string
+	Balance    Money
+	Currency   string
+	GoalAmount Money `json:"goal_amount"`
+	RoundUp    bool  `json:"round_up"`
+	Locked     bool
+	Deleted    bool
+	Created    time.Time
+	Updated    time.Time
+}
+
+// GoalProgress returns the pot balance as a percentage of its goal, and false
+// if the pot has no goal.
+func (p *Pot) GoalProgress() (float64, bool) {
+	if p.GoalAmount.Amount <= 0 {
+		return 0, false
+	}
+
+	return float64(p.Balance.Amount) / float64(p.GoalAmount.Amount) * 100, true
+}
+
+// UnmarshalJSON decodes the balance and goal, which the API sends in minor
+// units, into Money in the pot currency.
+func (p *Pot) UnmarshalJSON(data []byte) error {
+	type pot Pot
+	v := struct {
+		*pot
+		Balance    int64
+		GoalAmount int64 `json:"goal_amount"`
+	}{pot: (*pot)(p)}
+
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	p.Balance = NewMoney(v.Balance, p.Currency)
+	p.GoalAmount = NewMoney(v.GoalAmount, p.Currency)
+	return nil
 }
 
 type PotList struct {
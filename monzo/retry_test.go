@@ -0,0 +1,146 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monzo_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+	"github.com/jammystuff/pennychallenge/monzotest"
+)
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(server)
+
+	server.FailNext("/accounts", 503, "internal_service")
+	server.FailNext("/accounts", 500, "internal_service")
+
+	_, err := client.Accounts(context.Background())
+	if err != nil {
+		t.Fatalf("Accounts: %v", err)
+	}
+	if got := server.Requests("/accounts"); got != 3 {
+		t.Errorf("%d requests, want 3", got)
+	}
+}
+
+func TestClientRetriesDroppedConnections(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(server)
+
+	server.DropNext("/accounts")
+
+	_, err := client.Accounts(context.Background())
+	if err != nil {
+		t.Fatalf("Accounts: %v", err)
+	}
+	if got := server.Requests("/accounts"); got != 2 {
+		t.Errorf("%d requests, want 2", got)
+	}
+}
+
+func TestClientHonoursRetryAfter(t *testing.T) {
+	server := newTestServer(t)
+	client := monzo.NewClient(monzotest.AccessToken,
+		monzo.WithBaseURL(server.URL),
+		monzo.WithRetryPolicy(monzo.RetryPolicy{
+			MaxRetries: 3,
+			MinBackoff: time.Millisecond,
+			MaxBackoff: 5 * time.Second,
+		}))
+
+	header := http.Header{"Retry-After": {"1"}}
+	server.FailNextWithHeader("/accounts", 429, "too_many_requests", header)
+
+	start := time.Now()
+	_, err := client.Accounts(context.Background())
+	if err != nil {
+		t.Fatalf("Accounts: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least 1s", elapsed)
+	}
+	if got := server.Requests("/accounts"); got != 2 {
+		t.Errorf("%d requests, want 2", got)
+	}
+}
+
+func TestClientGivesUpOnLongRetryAfter(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(server)
+
+	header := http.Header{"Retry-After": {"3600"}}
+	server.FailNextWithHeader("/accounts", 429, "too_many_requests", header)
+
+	_, err := client.Accounts(context.Background())
+	if !monzo.IsRateLimited(err) {
+		t.Fatalf("got error %v, want rate limited", err)
+	}
+	if got := server.Requests("/accounts"); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
+
+func TestClientGivesUpOnRetryAfterPastDeadline(t *testing.T) {
+	server := newTestServer(t)
+	client := monzo.NewClient(monzotest.AccessToken,
+		monzo.WithBaseURL(server.URL),
+		monzo.WithRetryPolicy(monzo.RetryPolicy{
+			MaxRetries: 3,
+			MinBackoff: time.Millisecond,
+			MaxBackoff: time.Minute,
+		}))
+
+	header := http.Header{"Retry-After": {"30"}}
+	server.FailNextWithHeader("/accounts", 429, "too_many_requests", header)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := client.Accounts(ctx)
+	if !monzo.IsRateLimited(err) {
+		t.Fatalf("got error %v, want rate limited", err)
+	}
+}
+
+func TestClientDoesNotRetryPost(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(server)
+
+	server.FailNext("/oauth2/logout", 503, "internal_service")
+
+	err := client.Logout(context.Background())
+	if err == nil {
+		t.Fatal("Logout succeeded, want error")
+	}
+	if got := server.Requests("/oauth2/logout"); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monzo
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RefreshMargin is how long before its expiry a token is refreshed, so that
+// it doesn't expire part way through a run.
+const RefreshMargin = 5 * time.Minute
+
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	UserID       string    `json:"user_id"`
+	ClientID     string    `json:"client_id"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// Expired reports whether the token is known to have expired. Tokens without
+// an expiry are assumed to be valid until the API rejects them.
+func (t *Token) Expired() bool {
+	return t.ExpiresWithin(0)
+}
+
+// ExpiresWithin reports whether the token is known to expire within d.
+func (t *Token) ExpiresWithin(d time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Now().Add(d).After(t.Expiry)
+}
+
+// TokenSource supplies access tokens to a Client. Refresh is called when the
+// API rejects the current token.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+	Refresh(ctx context.Context) (*Token, error)
+}
+
+// RefreshTokenSource is a TokenSource that loads its token from a TokenStore,
+// refreshes it using the OAuth refresh token when it has expired or been
+// rejected, and saves the rotated token back to the store.
+type RefreshTokenSource struct {
+	clientID     string
+	clientSecret string
+	tokenURL     string
+	store        TokenStore
+	httpClient   *http.Client
+
+	// OnRefresh, if set, is called with each new token after a refresh. As
+	// refresh tokens are rotated, this lets callers pass on tokens that a
+	// read-only or in-memory store can't keep.
+	OnRefresh func(token *Token)
+
+	mu    sync.Mutex
+	token *Token
+}
+
+func NewRefreshTokenSource(clientID, clientSecret, tokenURL string, store TokenStore) *RefreshTokenSource {
+	return &RefreshTokenSource{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		tokenURL:     tokenURL,
+		store:        store,
+		httpClient:   &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+func (s *RefreshTokenSource) Refresh(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		token, err := s.store.Load()
+		if err != nil {
+			return nil, err
+		}
+		s.token = token
+	}
+
+	return s.refresh(ctx)
+}
+
+// refresh must be called with s.mu held and s.token loaded. If the store is a
+// TokenLocker, the lock is held until the refreshed token has been saved, and
+// a token refreshed by another process in the meantime is used instead.
+func (s *RefreshTokenSource) refresh(ctx context.Context) (*Token, error) {
+	if locker, ok := s.store.(TokenLocker); ok {
+		unlock, err := locker.Lock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+
+		current, err := s.store.Load()
+		if err == nil && current.RefreshToken != s.token.RefreshToken {
+			s.token = current
+			if !current.ExpiresWithin(RefreshMargin) {
+				return current, nil
+			}
+		}
+	}
+
+	values := url.Values{}
+	values.Add("grant_type", "refresh_token")
+	values.Add("client_id", s.clientID)
+	values.Add("client_secret", s.clientSecret)
+	values.Add("refresh_token", s.token.RefreshToken)
+
+	token, err := requestToken(ctx, s.httpClient, s.tokenURL, values)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.Save(token)
+	if err != nil && err != ErrReadOnlyStore {
+		return nil, err
+	}
+
+	s.token = token
+	if s.OnRefresh != nil {
+		s.OnRefresh(token)
+	}
+
+	return token, nil
+}
+
+func (s *RefreshTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		token, err := s.store.Load()
+		if err != nil {
+			return nil, err
+		}
+		s.token = token
+	}
+
+	if s.token.ExpiresWithin(RefreshMargin) {
+		return s.refresh(ctx)
+	}
+
+	return s.token, nil
+}
+
+// ExchangeCode exchanges an authorization code from the OAuth redirect for a
+// token.
+func ExchangeCode(ctx context.Context, clientID, clientSecret, tokenURL, redirectURL, code string) (*Token, error) {
+	values := url.Values{}
+	values.Add("grant_type", "authorization_code")
+	values.Add("client_id", clientID)
+	values.Add("client_secret", clientSecret)
+	values.Add("redirect_uri", redirectURL)
+	values.Add("code", code)
+
+	httpClient := &http.Client{Timeout: DefaultTimeout}
+	return requestToken(ctx, httpClient, tokenURL, values)
+}
+
+func requestToken(ctx context.Context, httpClient *http.Client, tokenURL string, values url.Values) (*Token, error) {
+	body := strings.NewReader(values.Encode())
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError("/oauth2/token", resp)
+	}
+
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		UserID       string `json:"user_id"`
+		ClientID     string `json:"client_id"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	err = json.Unmarshal(respBody, &tokenResp)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		TokenType:    tokenResp.TokenType,
+		UserID:       tokenResp.UserID,
+		ClientID:     tokenResp.ClientID,
+	}
+	if tokenResp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
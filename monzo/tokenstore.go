@@ -0,0 +1,161 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monzo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// ErrReadOnlyStore is returned by Save on a TokenStore that can't persist
+// tokens. A RefreshTokenSource keeps using a refreshed token in memory when
+// its store is read-only.
+var ErrReadOnlyStore = errors.New("Token store is read-only")
+
+// TokenStore persists the token used by a RefreshTokenSource, so that rotated
+// refresh tokens survive between runs.
+type TokenStore interface {
+	Load() (*Token, error)
+	Save(token *Token) error
+	Delete() error
+}
+
+// EnvTokenStore reads a token from environment variables, for deployments
+// without a writable disk. It is read-only.
+type EnvTokenStore struct {
+	AccessTokenVar  string
+	RefreshTokenVar string
+}
+
+func (s *EnvTokenStore) Delete() error {
+	return ErrReadOnlyStore
+}
+
+func (s *EnvTokenStore) Load() (*Token, error) {
+	token := &Token{
+		AccessToken:  os.Getenv(s.AccessTokenVar),
+		RefreshToken: os.Getenv(s.RefreshTokenVar),
+	}
+
+	if token.AccessToken == "" && token.RefreshToken == "" {
+		msg := fmt.Sprintf("Neither %s nor %s is set", s.AccessTokenVar, s.RefreshTokenVar)
+		return nil, errors.New(msg)
+	}
+
+	return token, nil
+}
+
+func (s *EnvTokenStore) Save(token *Token) error {
+	return ErrReadOnlyStore
+}
+
+// FileTokenStore stores a token as JSON in a file readable only by its owner,
+// creating the directory containing the file if needed.
+type FileTokenStore struct {
+	Path string
+}
+
+func (s *FileTokenStore) Delete() error {
+	return deleteFile(s.Path)
+}
+
+func (s *FileTokenStore) Load() (*Token, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var token Token
+	err = json.Unmarshal(data, &token)
+	return &token, err
+}
+
+// Lock takes an advisory lock on the token file. See TokenLocker.
+func (s *FileTokenStore) Lock(ctx context.Context) (func(), error) {
+	return lockFile(ctx, s.Path+".lock")
+}
+
+func (s *FileTokenStore) Save(token *Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.Path, data, 0600)
+}
+
+// MemoryTokenStore keeps a token in memory only.
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token *Token
+}
+
+func NewMemoryTokenStore(token *Token) *MemoryTokenStore {
+	return &MemoryTokenStore{token: token}
+}
+
+func (s *MemoryTokenStore) Delete() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = nil
+	return nil
+}
+
+func (s *MemoryTokenStore) Load() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		return nil, errors.New("No token in memory")
+	}
+
+	token := *s.token
+	return &token, nil
+}
+
+func (s *MemoryTokenStore) Save(token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *token
+	s.token = &saved
+	return nil
+}
+
+// deleteFile removes path, treating a file that doesn't exist as deleted.
+func deleteFile(path string) error {
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
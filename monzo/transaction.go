@@ -0,0 +1,153 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package monzo
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type Merchant struct {
+	ID       string
+	GroupID  string `json:"group_id"`
+	Name     string
+	Logo     string
+	Emoji    string
+	Category string
+}
+
+type Transaction struct {
+	ID            string
+	Amount        Money
+	Currency      string
+	CreatedStr    string `json:"created"`
+	SettledStr    string `json:"settled"`
+	Category      string
+	Description   string
+	Notes         string
+	Merchant      *Merchant
+	Metadata      map[string]string
+	DeclineReason string `json:"decline_reason"`
+}
+
+func (tx *Transaction) Created() time.Time {
+	created, _ := time.Parse(time.RFC3339, tx.CreatedStr)
+	return created
+}
+
+func (tx *Transaction) Declined() bool {
+	return tx.DeclineReason != ""
+}
+
+func (tx *Transaction) Settled() (time.Time, bool) {
+	if tx.SettledStr == "" {
+		return time.Time{}, false
+	}
+
+	settled, err := time.Parse(time.RFC3339, tx.SettledStr)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return settled, true
+}
+
+// UnmarshalJSON decodes the amount, which the API sends in minor units, into
+// Money in the transaction currency.
+func (tx *Transaction) UnmarshalJSON(data []byte) error {
+	type transaction Transaction
+	v := struct {
+		*transaction
+		Amount int64
+	}{transaction: (*transaction)(tx)}
+
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	tx.Amount = NewMoney(v.Amount, tx.Currency)
+	return nil
+}
+
+type TransactionList struct {
+	Transactions []Transaction
+}
+
+// TransactionIterator walks through the transactions of an account a page at
+// a time, using the ID of the last transaction seen as the since cursor for
+// the next page.
+type TransactionIterator struct {
+	ctx       context.Context
+	client    *Client
+	accountID string
+	since     string
+	before    time.Time
+	page      []Transaction
+	current   *Transaction
+	done      bool
+	err       error
+}
+
+func (it *TransactionIterator) Err() error {
+	return it.err
+}
+
+func (it *TransactionIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if len(it.page) == 0 {
+		if it.done {
+			return false
+		}
+
+		page, err := it.client.Transactions(it.ctx, it.accountID, it.since, it.before, TransactionPageSize)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = *page
+		if len(it.page) < TransactionPageSize {
+			it.done = true
+		}
+		if len(it.page) == 0 {
+			return false
+		}
+		it.since = it.page[len(it.page)-1].ID
+	}
+
+	it.current = &it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+func (it *TransactionIterator) Transaction() *Transaction {
+	return it.current
+}
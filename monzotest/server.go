@@ -0,0 +1,658 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package monzotest provides an in-process fake of the Monzo API for use in
+// tests. The fake keeps accounts, pots, balances, transactions and OAuth tokens
+// in memory, honours dedupe IDs on pot deposits and withdrawals, and can be
+// told to fail requests to a given endpoint.
+package monzotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+)
+
+const dateFormat = "2006-01-02"
+
+const (
+	AccessToken  = "test-access-token"
+	AuthCode     = "test-auth-code"
+	ClientID     = "test-client-id"
+	ClientSecret = "test-client-secret"
+	RefreshToken = "test-refresh-token"
+	UserID       = "test-user-id"
+)
+
+type failure struct {
+	status int
+	code   string
+	header http.Header
+	drop   bool
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	approved     bool
+	accessToken  string
+	refreshToken string
+	tokenCount   int
+	accounts     []monzo.Account
+	balances     map[string]int64
+	pots         []monzo.Pot
+	transactions []monzo.Transaction
+	txAccounts   map[string]string
+	dedupeIDs    map[string]bool
+	failures     map[string][]failure
+	requests     map[string]int
+}
+
+// NewServer starts a fake Monzo API server with no accounts or pots. It
+// accepts AccessToken until the token is refreshed. Callers should Close the
+// server when finished.
+func NewServer() *Server {
+	s := &Server{
+		approved:     true,
+		accessToken:  AccessToken,
+		refreshToken: RefreshToken,
+		balances:     make(map[string]int64),
+		txAccounts:   make(map[string]string),
+		dedupeIDs:    make(map[string]bool),
+		failures:     make(map[string][]failure),
+		requests:     make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/accounts", s.handleAccounts)
+	mux.HandleFunc("/balance", s.handleBalance)
+	mux.HandleFunc("/oauth2/logout", s.handleLogout)
+	mux.HandleFunc("/oauth2/token", s.handleToken)
+	mux.HandleFunc("/ping/whoami", s.handleWhoAmI)
+	mux.HandleFunc("/pots", s.handlePots)
+	mux.HandleFunc("/pots/", s.handlePot)
+	mux.HandleFunc("/transactions", s.handleTransactions)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func (s *Server) AddAccount(account monzo.Account, balance int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accounts = append(s.accounts, account)
+	s.balances[account.ID] = balance
+}
+
+// AddPot adds a pot. If the pot has no currency or timestamps, they are filled
+// in.
+func (s *Server) AddPot(pot monzo.Pot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pot.Currency == "" {
+		pot.Currency = "GBP"
+	}
+	pot.Balance.Currency = pot.Currency
+	pot.GoalAmount.Currency = pot.Currency
+	if pot.Created.IsZero() {
+		pot.Created = time.Now().UTC()
+	}
+	if pot.Updated.IsZero() {
+		pot.Updated = pot.Created
+	}
+
+	s.pots = append(s.pots, pot)
+}
+
+// AddTransaction records a transaction against an account. If the transaction
+// has no ID or creation time, they are filled in.
+func (s *Server) AddTransaction(accountID string, tx monzo.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addTransaction(accountID, tx)
+}
+
+func (s *Server) addTransaction(accountID string, tx monzo.Transaction) {
+	if tx.ID == "" {
+		tx.ID = fmt.Sprintf("tx_%08d", len(s.transactions)+1)
+	}
+	if tx.CreatedStr == "" {
+		tx.CreatedStr = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	if tx.Currency == "" {
+		tx.Currency = "GBP"
+	}
+	tx.Amount.Currency = tx.Currency
+
+	s.transactions = append(s.transactions, tx)
+	s.txAccounts[tx.ID] = accountID
+}
+
+func (s *Server) Balance(accountID string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.balances[accountID]
+}
+
+// DropNext makes the next request to endpoint fail by closing the connection
+// without a response. It is queued along with failures from FailNext.
+func (s *Server) DropNext(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[endpoint] = append(s.failures[endpoint], failure{drop: true})
+}
+
+// FailNext makes the next request to endpoint fail with the given status and
+// error code. Endpoints are named as in monzo.APIError, e.g. "/pots/deposit".
+// Calling FailNext repeatedly queues several failures.
+func (s *Server) FailNext(endpoint string, status int, code string) {
+	s.FailNextWithHeader(endpoint, status, code, nil)
+}
+
+// FailNextWithHeader is like FailNext, but also sets header on the response,
+// e.g. Retry-After.
+func (s *Server) FailNextWithHeader(endpoint string, status int, code string, header http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[endpoint] = append(s.failures[endpoint], failure{status: status, code: code, header: header})
+}
+
+func (s *Server) PotBalance(potID string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pot := s.findPot(potID)
+	if pot == nil {
+		return 0
+	}
+	return pot.Balance.Amount
+}
+
+// Requests returns the number of requests made to endpoint, including those
+// that failed.
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[endpoint]
+}
+
+// SetApproved sets whether the user has approved access in the Monzo app.
+// Until they have, requests for account data fail with a 403 status code, as
+// Strong Customer Authentication requires.
+func (s *Server) SetApproved(approved bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.approved = approved
+}
+
+// Token returns the access and refresh tokens the server currently accepts.
+func (s *Server) Token() (accessToken, refreshToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.accessToken, s.refreshToken
+}
+
+// checkRequest applies any queued failure for endpoint and checks the access
+// token. It must be called with s.mu held, and returns false if an error
+// response has been written.
+func (s *Server) checkRequest(w http.ResponseWriter, r *http.Request, endpoint string) bool {
+	s.requests[endpoint]++
+
+	if queue := s.failures[endpoint]; len(queue) > 0 {
+		s.failures[endpoint] = queue[1:]
+		injectFailure(w, queue[0])
+		return false
+	}
+
+	if endpoint == "/oauth2/token" {
+		return true
+	}
+
+	if s.accessToken == "" || r.Header.Get("Authorization") != "Bearer "+s.accessToken {
+		writeError(w, http.StatusUnauthorized, "unauthorized.bad_access_token", "Access token is invalid")
+		return false
+	}
+
+	if !s.approved && !strings.HasPrefix(endpoint, "/oauth2/") && endpoint != "/ping/whoami" {
+		writeError(w, http.StatusForbidden, "forbidden.insufficient_permissions", "Access has not been approved in the app")
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) findAccount(id string) *monzo.Account {
+	for i := range s.accounts {
+		if s.accounts[i].ID == id {
+			return &s.accounts[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) findPot(id string) *monzo.Pot {
+	for i := range s.pots {
+		if s.pots[i].ID == id {
+			return &s.pots[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.checkRequest(w, r, "/accounts") {
+		return
+	}
+
+	accounts := []map[string]interface{}{}
+	for _, account := range s.accounts {
+		accounts = append(accounts, map[string]interface{}{
+			"id":          account.ID,
+			"description": account.Description,
+			"type":        account.TypeStr,
+		})
+	}
+
+	writeJSON(w, map[string]interface{}{"accounts": accounts})
+}
+
+func (s *Server) handleBalance(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.checkRequest(w, r, "/balance") {
+		return
+	}
+
+	accountID := r.URL.Query().Get("account_id")
+	if s.findAccount(accountID) == nil {
+		writeError(w, http.StatusNotFound, "not_found.account", "Account not found")
+		return
+	}
+
+	balance := s.balances[accountID]
+	totalBalance := balance
+	for _, pot := range s.pots {
+		if !pot.Deleted {
+			totalBalance += pot.Balance.Amount
+		}
+	}
+
+	var spendToday int64
+	today := time.Now().UTC().Format(dateFormat)
+	for _, tx := range s.transactions {
+		if s.txAccounts[tx.ID] == accountID && tx.Amount.Amount < 0 && tx.Category != "savings" &&
+			tx.Created().UTC().Format(dateFormat) == today {
+			spendToday += tx.Amount.Amount
+		}
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"balance":                            balance,
+		"total_balance":                      totalBalance,
+		"balance_including_flexible_savings": balance,
+		"currency":                           "GBP",
+		"spend_today":                        spendToday,
+	})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.checkRequest(w, r, "/oauth2/logout") {
+		return
+	}
+
+	s.accessToken = ""
+	s.refreshToken = ""
+	writeJSON(w, map[string]interface{}{})
+}
+
+// handlePot handles deposits to and withdrawals from /pots/{id}.
+func (s *Server) handlePot(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/pots/"), "/")
+	if len(parts) != 2 || (parts[1] != "deposit" && parts[1] != "withdraw") {
+		writeError(w, http.StatusNotFound, "not_found", "Not found")
+		return
+	}
+	potID, action := parts[0], parts[1]
+
+	if !s.checkRequest(w, r, "/pots/"+action) {
+		return
+	}
+
+	if r.Method != "PUT" {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		return
+	}
+
+	pot := s.findPot(potID)
+	if pot == nil || pot.Deleted {
+		writeError(w, http.StatusNotFound, "not_found.pot", "Pot not found")
+		return
+	}
+	if action == "withdraw" && pot.Locked {
+		writeError(w, http.StatusForbidden, "forbidden.pot_locked", "Pot is locked")
+		return
+	}
+
+	accountParam := "source_account_id"
+	if action == "withdraw" {
+		accountParam = "destination_account_id"
+	}
+	accountID := r.PostFormValue(accountParam)
+	if s.findAccount(accountID) == nil {
+		writeError(w, http.StatusBadRequest, "bad_request.invalid_account", "Account not found")
+		return
+	}
+
+	amount, err := strconv.ParseInt(r.PostFormValue("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		writeError(w, http.StatusBadRequest, "bad_request.invalid_amount", "Invalid amount")
+		return
+	}
+
+	dedupeID := r.PostFormValue("dedupe_id")
+	if dedupeID == "" {
+		writeError(w, http.StatusBadRequest, "bad_request.missing_dedupe_id", "Missing dedupe_id")
+		return
+	}
+
+	key := potID + "/" + action + "/" + dedupeID
+	if !s.dedupeIDs[key] {
+		if action == "deposit" {
+			if s.balances[accountID] < amount {
+				writeError(w, http.StatusBadRequest, "bad_request.insufficient_funds", "Insufficient funds")
+				return
+			}
+			s.balances[accountID] -= amount
+			pot.Balance.Amount += amount
+			amount = -amount
+		} else {
+			if pot.Balance.Amount < amount {
+				writeError(w, http.StatusBadRequest, "bad_request.insufficient_funds", "Insufficient funds in pot")
+				return
+			}
+			pot.Balance.Amount -= amount
+			s.balances[accountID] += amount
+		}
+
+		pot.Updated = time.Now().UTC()
+		s.dedupeIDs[key] = true
+		s.addTransaction(accountID, monzo.Transaction{
+			Amount:      monzo.NewMoney(amount, "GBP"),
+			Category:    "savings",
+			Description: potID,
+			Metadata:    map[string]string{"pot_id": potID, "dedupe_id": dedupeID},
+		})
+	}
+
+	writeJSON(w, potJSON(pot))
+}
+
+func (s *Server) handlePots(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.checkRequest(w, r, "/pots") {
+		return
+	}
+
+	pots := []map[string]interface{}{}
+	for _, pot := range s.pots {
+		pots = append(pots, potJSON(&pot))
+	}
+
+	writeJSON(w, map[string]interface{}{"pots": pots})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.checkRequest(w, r, "/oauth2/token") {
+		return
+	}
+
+	if r.PostFormValue("client_id") != ClientID || r.PostFormValue("client_secret") != ClientSecret {
+		writeError(w, http.StatusUnauthorized, "unauthorized.bad_client", "Invalid client credentials")
+		return
+	}
+
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		if r.PostFormValue("code") != AuthCode {
+			writeError(w, http.StatusUnauthorized, "unauthorized.bad_authorization_code", "Invalid authorization code")
+			return
+		}
+	case "refresh_token":
+		if r.PostFormValue("refresh_token") != s.refreshToken {
+			writeError(w, http.StatusUnauthorized, "unauthorized.bad_refresh_token", "Invalid refresh token")
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request.unsupported_grant_type", "Unsupported grant type")
+		return
+	}
+
+	s.tokenCount++
+	s.accessToken = fmt.Sprintf("%s-%d", AccessToken, s.tokenCount)
+	s.refreshToken = fmt.Sprintf("%s-%d", RefreshToken, s.tokenCount)
+
+	writeJSON(w, map[string]interface{}{
+		"access_token":  s.accessToken,
+		"refresh_token": s.refreshToken,
+		"expires_in":    21600,
+		"token_type":    "Bearer",
+		"user_id":       UserID,
+		"client_id":     ClientID,
+	})
+}
+
+func (s *Server) handleTransactions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.checkRequest(w, r, "/transactions") {
+		return
+	}
+
+	query := r.URL.Query()
+	accountID := query.Get("account_id")
+	if s.findAccount(accountID) == nil {
+		writeError(w, http.StatusNotFound, "not_found.account", "Account not found")
+		return
+	}
+
+	var txs []monzo.Transaction
+	for _, tx := range s.transactions {
+		if s.txAccounts[tx.ID] == accountID {
+			txs = append(txs, tx)
+		}
+	}
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].Created().Before(txs[j].Created())
+	})
+
+	if since := query.Get("since"); since != "" {
+		txs = filterSince(txs, since)
+	}
+	if before, err := time.Parse(time.RFC3339, query.Get("before")); err == nil {
+		var filtered []monzo.Transaction
+		for _, tx := range txs {
+			if tx.Created().Before(before) {
+				filtered = append(filtered, tx)
+			}
+		}
+		txs = filtered
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit < len(txs) {
+		txs = txs[:limit]
+	}
+
+	transactions := []map[string]interface{}{}
+	for _, tx := range txs {
+		transaction := map[string]interface{}{
+			"id":             tx.ID,
+			"amount":         tx.Amount.Amount,
+			"currency":       tx.Currency,
+			"created":        tx.CreatedStr,
+			"settled":        tx.SettledStr,
+			"category":       tx.Category,
+			"description":    tx.Description,
+			"notes":          tx.Notes,
+			"metadata":       tx.Metadata,
+			"decline_reason": tx.DeclineReason,
+			"merchant":       nil,
+		}
+		if tx.Merchant != nil {
+			transaction["merchant"] = map[string]interface{}{
+				"id":       tx.Merchant.ID,
+				"group_id": tx.Merchant.GroupID,
+				"name":     tx.Merchant.Name,
+				"logo":     tx.Merchant.Logo,
+				"emoji":    tx.Merchant.Emoji,
+				"category": tx.Merchant.Category,
+			}
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	writeJSON(w, map[string]interface{}{"transactions": transactions})
+}
+
+func (s *Server) handleWhoAmI(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.checkRequest(w, r, "/ping/whoami") {
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"authenticated": true,
+		"client_id":     ClientID,
+		"user_id":       UserID,
+	})
+}
+
+// filterSince returns the transactions after since, which is either a
+// transaction ID or an RFC 3339 timestamp.
+func filterSince(txs []monzo.Transaction, since string) []monzo.Transaction {
+	for i, tx := range txs {
+		if tx.ID == since {
+			return txs[i+1:]
+		}
+	}
+
+	sinceTime, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return nil
+	}
+
+	var filtered []monzo.Transaction
+	for _, tx := range txs {
+		if !tx.Created().Before(sinceTime) {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// injectFailure writes the response for f, or closes the connection if f
+// drops it.
+func injectFailure(w http.ResponseWriter, f failure) {
+	if f.drop {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	for key, values := range f.header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	writeError(w, f.status, f.code, "injected failure")
+}
+
+func potJSON(pot *monzo.Pot) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          pot.ID,
+		"name":        pot.Name,
+		"style":       pot.Style,
+		"balance":     pot.Balance.Amount,
+		"currency":    pot.Currency,
+		"goal_amount": pot.GoalAmount.Amount,
+		"round_up":    pot.RoundUp,
+		"locked":      pot.Locked,
+		"deleted":     pot.Deleted,
+		"created":     pot.Created.Format(time.RFC3339Nano),
+		"updated":     pot.Updated.Format(time.RFC3339Nano),
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":    code,
+		"message": message,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
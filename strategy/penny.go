@@ -0,0 +1,135 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+)
+
+const (
+	dateFormat  = "2006-01-02"
+	daysPerYear = 365
+)
+
+// PennyChallenge saves 1p on the first day of the year, 2p on the second and
+// so on. When Reversed is set, the amounts run from 365p (or 366p) down to 1p
+// instead, so that the largest savings don't fall in December.
+//
+// Each day's step is multiplied by Unit, which is 1p by default, and Offset is
+// added to it. If Target is set, the steps are scaled to save exactly Target
+// over the year instead.
+type PennyChallenge struct {
+	Unit     monzo.Money
+	Offset   monzo.Money
+	Target   monzo.Money
+	Reversed bool
+}
+
+func newForward(opts Options) (Strategy, error) {
+	return newPennyChallenge(opts, false), nil
+}
+
+func newPennyChallenge(opts Options, reversed bool) *PennyChallenge {
+	unit := opts.Unit
+	if unit.IsZero() {
+		unit = monzo.NewMoney(1, opts.Currency)
+	}
+
+	return &PennyChallenge{Unit: unit, Offset: opts.Offset, Target: opts.Target, Reversed: reversed}
+}
+
+func newReversed(opts Options) (Strategy, error) {
+	return newPennyChallenge(opts, true), nil
+}
+
+func (p *PennyChallenge) Deposit(ctx context.Context, date time.Time) (monzo.Money, string, error) {
+	id := fmt.Sprintf("PENNY-%s", date.Format(dateFormat))
+	if !p.Target.IsZero() {
+		amount, err := p.targetAmount(date)
+		return amount, id, err
+	}
+
+	amount, err := scale(p.step(date), p.Unit, p.Offset)
+	return amount, id, err
+}
+
+// cumulative returns the sum of the steps from the start of the year up to and
+// including day, in a year of days days.
+func (p *PennyChallenge) cumulative(day, days int64) int64 {
+	forward := day * (day + 1) / 2
+	if !p.Reversed {
+		return forward
+	}
+
+	return day*(days+1) - forward
+}
+
+// step returns the number of pennies to save on date.
+func (p *PennyChallenge) step(date time.Time) int64 {
+	yearDay := int64(date.YearDay())
+	if !p.Reversed {
+		return yearDay
+	}
+
+	days := int64(daysInYear(date))
+	return days + 1 - yearDay
+}
+
+// targetAmount returns the share of Target to save on date. The amount saved by
+// the end of each day is Target scaled by the fraction of the year's steps done
+// so far, rounded down, so rounding remainders fall on the same days every
+// year and the deposits add up to exactly Target.
+func (p *PennyChallenge) targetAmount(date time.Time) (monzo.Money, error) {
+	days := int64(daysInYear(date))
+	yearDay := int64(date.YearDay())
+
+	total := p.cumulative(days, days)
+	if p.Target.Amount > math.MaxInt64/total {
+		return monzo.Money{}, errors.New("Target too large")
+	}
+
+	saved := p.Target.Amount * p.cumulative(yearDay, days) / total
+	savedBefore := p.Target.Amount * p.cumulative(yearDay-1, days) / total
+	return monzo.NewMoney(saved-savedBefore, p.Target.Currency), nil
+}
+
+func daysInYear(date time.Time) int {
+	year := date.Year()
+	if year%4 != 0 {
+		return daysPerYear
+	} else if year%100 != 0 {
+		return daysPerYear + 1
+	} else if year%400 != 0 {
+		return daysPerYear
+	}
+	return daysPerYear + 1
+}
@@ -0,0 +1,69 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+)
+
+func TestTargetTotal(t *testing.T) {
+	tests := []struct {
+		name   string
+		year   int
+		target int64
+	}{
+		{"forward", 2024, 1},
+		{"forward", 2024, 1000},
+		{"forward", 2025, 1},
+		{"forward", 2025, 1000},
+		{"forward", 2025, 100000},
+		{"reversed", 2024, 1},
+		{"reversed", 2024, 1000},
+		{"reversed", 2025, 1},
+		{"reversed", 2025, 1000},
+		{"reversed", 2025, 100000},
+	}
+
+	for _, test := range tests {
+		target := monzo.NewMoney(test.target, "GBP")
+		s, err := New(test.name, Options{Currency: "GBP", Target: target})
+		if err != nil {
+			t.Fatalf("New(%s): %v", test.name, err)
+		}
+
+		total, err := Total(context.Background(), s, test.year)
+		if err != nil {
+			t.Errorf("%s %d %s: %v", test.name, test.year, target, err)
+			continue
+		}
+		if total != target {
+			t.Errorf("%s %d %s: total %s, want %s", test.name, test.year, target, total, target)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+// Package strategy implements the savings schemes that decide how much to save
+// each day.
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+)
+
+// Default is the strategy used when none is configured.
+const Default = "reversed"
+
+// Strategy decides how much to save on a given date.
+type Strategy interface {
+	// Deposit returns the amount to save on date and an ID that makes the
+	// deposit idempotent, so that running more than once on the same date
+	// only saves once. A zero amount means nothing should be saved.
+	Deposit(ctx context.Context, date time.Time) (monzo.Money, string, error)
+}
+
+// Totaler is implemented by strategies whose deposits don't follow the calendar
+// year, so that Total can't simply add up the deposits for each day.
+type Totaler interface {
+	// Total returns the sum of the deposits for year.
+	Total(year int) (monzo.Money, error)
+}
+
+// Options configures a strategy created by New.
+type Options struct {
+	// Currency is the currency of the pot being saved into.
+	Currency string
+
+	// Unit is the amount saved per step of the challenge, e.g. 2p per day of
+	// the year instead of 1p. If zero, each strategy uses its own default.
+	Unit monzo.Money
+
+	// Offset is a fixed amount added to every deposit.
+	Offset monzo.Money
+
+	// Target is the total to save over the year. Strategies that support it
+	// scale their deposits to add up to exactly Target instead of using Unit
+	// and Offset.
+	Target monzo.Money
+
+	// Weekday is the day of the week on which weekly strategies save. If that
+	// day is missed, they save on a later day of the same week instead.
+	Weekday time.Weekday
+}
+
+// Factory creates a strategy from opts.
+type Factory func(opts Options) (Strategy, error)
+
+var factories = map[string]Factory{}
+
+func init() {
+	Register("52week", newWeek)
+	Register("52week-reversed", newWeekReversed)
+	Register("forward", newForward)
+	Register("reversed", newReversed)
+}
+
+// Names returns the names of the registered strategies in sorted order.
+func Names() []string {
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// New creates the strategy registered as name, or the default strategy if name
+// is empty.
+func New(name string, opts Options) (Strategy, error) {
+	if name == "" {
+		name = Default
+	}
+
+	factory, ok := factories[name]
+	if !ok {
+		msg := fmt.Sprintf("Unknown strategy %s", name)
+		return nil, errors.New(msg)
+	}
+
+	if opts.Currency == "" {
+		opts.Currency = monzo.DefaultCurrency
+	}
+	if opts.Offset.IsZero() {
+		opts.Offset = monzo.NewMoney(0, opts.Currency)
+	}
+
+	if opts.Unit.Amount < 0 {
+		return nil, errors.New("Unit can't be negative")
+	}
+	if opts.Offset.Amount < 0 {
+		return nil, errors.New("Offset can't be negative")
+	}
+
+	if !opts.Target.IsZero() {
+		if !opts.Unit.IsZero() || !opts.Offset.IsZero() {
+			return nil, errors.New("A target can't be combined with a unit or offset")
+		}
+		if opts.Target.Amount < 0 {
+			return nil, errors.New("Target must be positive")
+		}
+	}
+
+	return factory(opts)
+}
+
+// Total returns the sum of the deposits s makes for year. Strategies that
+// implement Totaler report it themselves. Otherwise the deposits for each day of
+// the calendar year are added up, counting each dedupe ID once.
+func Total(ctx context.Context, s Strategy, year int) (monzo.Money, error) {
+	if totaler, ok := s.(Totaler); ok {
+		return totaler.Total(year)
+	}
+
+	var total monzo.Money
+	seen := map[string]bool{}
+	date := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for ; date.Year() == year; date = date.AddDate(0, 0, 1) {
+		amount, id, err := s.Deposit(ctx, date)
+		if err != nil {
+			return monzo.Money{}, err
+		}
+		if total.Currency == "" {
+			total.Currency = amount.Currency
+		}
+		if amount.IsZero() || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		total, err = total.Add(amount)
+		if err != nil {
+			return monzo.Money{}, err
+		}
+	}
+
+	return total, nil
+}
+
+// Register makes a strategy available to New under name. It panics if name is
+// already registered.
+func Register(name string, factory Factory) {
+	if _, ok := factories[name]; ok {
+		panic("strategy: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// scale returns the amount saved for step, i.e. step*unit + offset.
+func scale(step int64, unit, offset monzo.Money) (monzo.Money, error) {
+	amount, err := unit.Mul(step)
+	if err != nil {
+		return monzo.Money{}, err
+	}
+
+	return amount.Add(offset)
+}
@@ -0,0 +1,140 @@
+// Copyright © 2018 James Wheatley <james@jammy.co>
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jammystuff/pennychallenge/monzo"
+)
+
+const weeksInYear = 52
+
+// WeekChallenge saves £1 in the first week of the year, £2 in the second and so
+// on up to £52, or from £52 down to £1 when Reversed is set. Weeks are ISO
+// weeks, and nothing is saved in week 53 of the years that have one.
+//
+// The deposit is made on Weekday, or on any later day of the same ISO week if
+// that day's run was missed or skipped. The dedupe ID is the ISO week, so only
+// one deposit is made per week however often it runs.
+//
+// Each week's step is multiplied by Unit, which is £1 by default, and Offset
+// is added to it.
+type WeekChallenge struct {
+	Unit     monzo.Money
+	Offset   monzo.Money
+	Reversed bool
+	Weekday  time.Weekday
+}
+
+func newWeek(opts Options) (Strategy, error) {
+	return newWeekChallenge(opts, false)
+}
+
+func newWeekChallenge(opts Options, reversed bool) (Strategy, error) {
+	if !opts.Target.IsZero() {
+		return nil, errors.New("The 52-week challenge doesn't support a target")
+	}
+
+	unit := opts.Unit
+	if unit.IsZero() {
+		var err error
+		unit, err = monzo.ParseMoney("1", opts.Currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &WeekChallenge{Unit: unit, Offset: opts.Offset, Reversed: reversed, Weekday: opts.Weekday}, nil
+}
+
+func newWeekReversed(opts Options) (Strategy, error) {
+	return newWeekChallenge(opts, true)
+}
+
+// ParseWeekday parses the English name of a day of the week, e.g. "monday" or
+// "Mon".
+func ParseWeekday(s string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		dayName := strings.ToLower(day.String())
+		if name == dayName || len(name) >= 3 && strings.HasPrefix(dayName, name) {
+			return day, nil
+		}
+	}
+
+	msg := fmt.Sprintf("Invalid weekday %q", s)
+	return time.Sunday, errors.New(msg)
+}
+
+func (w *WeekChallenge) Deposit(ctx context.Context, date time.Time) (monzo.Money, string, error) {
+	year, week := date.ISOWeek()
+	if isoWeekday(date.Weekday()) < isoWeekday(w.Weekday) || week > weeksInYear {
+		return monzo.NewMoney(0, w.Unit.Currency), "", nil
+	}
+
+	id := fmt.Sprintf("WEEK52-%d-W%02d", year, week)
+
+	amount, err := scale(w.step(week), w.Unit, w.Offset)
+	return amount, id, err
+}
+
+// Total returns the sum of the deposits for the ISO weeks of year, some of
+// which may fall in the previous or next calendar year.
+func (w *WeekChallenge) Total(year int) (monzo.Money, error) {
+	total := monzo.NewMoney(0, w.Unit.Currency)
+	for week := 1; week <= weeksInYear; week++ {
+		amount, err := scale(w.step(week), w.Unit, w.Offset)
+		if err != nil {
+			return monzo.Money{}, err
+		}
+
+		total, err = total.Add(amount)
+		if err != nil {
+			return monzo.Money{}, err
+		}
+	}
+
+	return total, nil
+}
+
+// step returns the number of whole currency units to save in week.
+func (w *WeekChallenge) step(week int) int64 {
+	if w.Reversed {
+		return int64(weeksInYear + 1 - week)
+	}
+	return int64(week)
+}
+
+// isoWeekday returns the position of day in an ISO week, which starts on
+// Monday.
+func isoWeekday(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}